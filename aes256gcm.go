@@ -1,10 +1,13 @@
 package ghoststring
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"crypto/sha1"
 
+	"github.com/pkg/errors"
 	"golang.org/x/crypto/argon2"
 )
 
@@ -34,35 +37,177 @@ func newAES256GCMKey(namespace, key string) ([]byte, error) {
 	), nil
 }
 
-func aes256GcmEncrypt(key, nonce []byte, plainText string) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+func aes256GcmGhostify(key []byte, gs *GhostString, valueHeader Header, opts *ghostifyerOptions) (string, error) {
+	nonce := make([]byte, Nonce)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	header, err := newHeader(gs, valueHeader, opts)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := compressPayload([]byte(gs.Str), header, opts)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err = padPayload(payload, header, opts)
+	if err != nil {
+		return "", err
+	}
+
+	namespaceLabel, err := toNamespaceLabel(gs.Namespace, header)
+	if err != nil {
+		return "", err
+	}
+
+	var additionalData []byte
+	if namespaceLabel != gs.Namespace {
+		additionalData = []byte(namespaceLabel)
+	}
+
+	encBytes, err := aes256GcmEncrypt(key, nonce, additionalData, payload)
+	if err != nil {
+		return "", err
+	}
+
+	return toGhostified(nonce, namespaceLabel, encBytes, opts.encoding), nil
+}
+
+// aes256GcmOpen attempts decryption with each key in order and
+// returns the plain text along with the index of the key that
+// succeeded.
+func aes256GcmOpen(keys [][]byte, unParts *unghostifyParts) ([]byte, int, error) {
+	var err error
+
+	for i, kb := range keys {
+		var plainText []byte
+
+		plainText, err = aes256GcmDecrypt(kb, unParts.nonce, unParts.additionalData, unParts.opaque)
+		if err == nil {
+			return plainText, i, nil
+		}
+	}
+
+	return nil, -1, err
+}
+
+// aes256GcmVerify builds the unghostified GhostString from
+// successfully decrypted parts and enforces any constraints carried
+// in the authenticated header.
+func aes256GcmVerify(unParts *unghostifyParts, plainText []byte, keyID string, opts *ghostifyerOptions) (*Unghostified, error) {
+	gs, err := aes256GcmUnseal(unParts, plainText, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	aesgcm, err := cipher.NewGCM(block)
+	if err := checkTimeLimit(gs, opts.now(), opts.clockSkew); err != nil {
+		return nil, err
+	}
+
+	if err := checkReplay(gs, opts); err != nil {
+		return nil, err
+	}
+
+	header := unParts.header
+	if header == nil {
+		header = Header{}
+	}
+
+	return &Unghostified{GhostString: gs, Header: header, KeyID: keyID}, nil
+}
+
+// aes256GcmUnseal builds the GhostString from successfully
+// decrypted parts without enforcing any constraints.
+func aes256GcmUnseal(unParts *unghostifyParts, plainText []byte, opts *ghostifyerOptions) (*GhostString, error) {
+	plainText, err := unpadPayload(plainText, unParts.header)
+	if err != nil {
+		return nil, err
+	}
+
+	plainText, err = decompressPayload(plainText, unParts.header, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return aesgcm.Seal(nil, nonce, []byte(plainText), nil), nil
+	gs := &GhostString{
+		Namespace: unParts.namespace,
+		Str:       string(plainText),
+		TokenID:   unParts.header[HeaderTokenID],
+	}
+
+	if err := readTimeLimitHeader(gs, unParts.header); err != nil {
+		return nil, err
+	}
+
+	return gs, nil
 }
 
-func aes256GcmDecrypt(key, nonce []byte, cipherText string) (string, error) {
+// aes256GcmRewrap ghostifies the value again with encKey if it was
+// ghostified with any other of the keys, retaining its issue time,
+// max age, token ID, header, and Encoding. Expiry and replay are not
+// checked, since rewrapping does not change either.
+func aes256GcmRewrap(encKey []byte, keys [][]byte, s string, opts *ghostifyerOptions) (string, bool, error) {
+	if isEmptyGhostified(s) {
+		return s, false, nil
+	}
+
+	unParts, err := toUnghostifyParts(s)
+	if err != nil {
+		return "", false, err
+	}
+
+	plainText, keyIndex, err := aes256GcmOpen(keys, unParts)
+	if err != nil {
+		return "", false, errors.Wrap(Err, "no valid decryption key")
+	}
+
+	if bytes.Equal(keys[keyIndex], encKey) {
+		return s, false, nil
+	}
+
+	gs, err := aes256GcmUnseal(unParts, plainText, opts)
+	if err != nil {
+		return "", false, err
+	}
+
+	rewrapOpts := *opts
+	rewrapOpts.encoding = encodingOf(s)
+
+	rewrapped, err := aes256GcmGhostify(encKey, gs, userHeader(unParts.header), &rewrapOpts)
+	if err != nil {
+		return "", false, err
+	}
+
+	return rewrapped, true, nil
+}
+
+func aes256GcmEncrypt(key, nonce, additionalData, plainText []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	aesgcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	plainText, err := aesgcm.Open(nil, nonce, []byte(cipherText), nil)
+	return aesgcm.Seal(nil, nonce, plainText, additionalData), nil
+}
+
+func aes256GcmDecrypt(key, nonce, additionalData, cipherText []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
 	}
 
-	return string(plainText), nil
+	return aesgcm.Open(nil, nonce, cipherText, additionalData)
 }
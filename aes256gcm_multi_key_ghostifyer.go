@@ -1,9 +1,8 @@
 package ghoststring
 
 import (
+	"bytes"
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 
 	"github.com/pkg/errors"
 )
@@ -13,82 +12,123 @@ import (
 // nonce assigned at the individual string level. The
 // keystore.Latest will be used for encryption and any key in
 // keystore.All may be used for decryption.
-func NewAES256GCMMultiKeyGhostifyer(namespace string, keys KeyStore) Ghostifyer {
+func NewAES256GCMMultiKeyGhostifyer(namespace string, keys KeyStore, opts ...GhostifyerOption) Ghostifyer {
 	return &aes256GcmMultiKeyGhostifyer{
 		ns:   namespace,
 		keys: keys,
+		opts: newGhostifyerOptions(opts),
 	}
 }
 
 type aes256GcmMultiKeyGhostifyer struct {
 	ns   string
 	keys KeyStore
+	opts *ghostifyerOptions
 }
 
 func (g *aes256GcmMultiKeyGhostifyer) Namespace() string { return g.ns }
 
 func (g *aes256GcmMultiKeyGhostifyer) Ghostify(gs *GhostString) (string, error) {
+	return g.GhostifyWithHeader(gs, nil)
+}
+
+func (g *aes256GcmMultiKeyGhostifyer) GhostifyWithHeader(gs *GhostString, header Header) (string, error) {
 	encKey, err := g.keys.Latest(context.TODO())
 	if err != nil {
 		return "", err
 	}
 
-	if gs == nil || !gs.IsValid() {
+	if !g.opts.shouldGhostify(gs) {
 		return "", nil
 	}
 
-	nonce := make([]byte, Nonce)
-	if _, err := rand.Read(nonce); err != nil {
-		return "", err
-	}
+	return aes256GcmGhostify(encKey, gs, header, g.opts)
+}
 
-	encBytes, err := aes256GcmEncrypt(encKey, nonce, gs.Str)
+func (g *aes256GcmMultiKeyGhostifyer) Unghostify(s string) (*GhostString, error) {
+	un, err := g.UnghostifyDetailed(s)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	b64Value := base64.StdEncoding.EncodeToString(
-		append(
-			append(
-				nonce,
-				[]byte(gs.Namespace+NamespaceSeparator)...,
-			),
-			encBytes...,
-		),
-	)
-
-	return Prefix + b64Value, nil
+	return un.GhostString, nil
 }
 
-func (g *aes256GcmMultiKeyGhostifyer) Unghostify(s string) (*GhostString, error) {
-	if s == Prefix || s == "" {
-		return &GhostString{}, nil
+func (g *aes256GcmMultiKeyGhostifyer) UnghostifyDetailed(s string) (*Unghostified, error) {
+	if isEmptyGhostified(s) {
+		return &Unghostified{GhostString: &GhostString{}, Header: Header{}}, nil
 	}
 
-	allKeys, err := g.keys.All(context.TODO())
+	encKey, allKeys, err := g.streamKeys(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	unParts, err := toUnghostifyParts(s)
+	if err != nil {
+		return nil, errors.Wrap(Err, "no valid decryption key")
+	}
+
+	plainText, keyIndex, err := aes256GcmOpen(allKeys, unParts)
+	if err != nil {
+		return nil, errors.Wrap(Err, "no valid decryption key")
+	}
+
+	un, err := aes256GcmVerify(unParts, plainText, aes256GcmKeyID(allKeys[keyIndex]), g.opts)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, kb := range allKeys {
-		if gs, err := g.tryUnghostify(kb, s); err == nil {
-			return gs, nil
+	un.KeyIndex = keyIndex
+	un.Latest = bytes.Equal(allKeys[keyIndex], encKey)
+
+	if tks, ok := g.keys.(TimestampedKeyStore); ok {
+		timestamps, err := tks.Timestamps(context.TODO())
+		if err == nil && keyIndex < len(timestamps) {
+			un.KeyTimestamp = timestamps[keyIndex]
 		}
 	}
 
-	return nil, errors.Wrap(Err, "no valid decryption key")
+	return un, nil
 }
 
-func (g *aes256GcmMultiKeyGhostifyer) tryUnghostify(kb []byte, s string) (*GhostString, error) {
-	unParts, err := toUnghostifyParts(s)
+func (g *aes256GcmMultiKeyGhostifyer) streamKeys(ctx context.Context) ([]byte, [][]byte, error) {
+	encKey, err := g.keys.Latest(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	plainBytes, err := aes256GcmDecrypt(kb, unParts.nonce, unParts.opaque)
+	allKeys, err := g.keys.All(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	return encKey, allKeys, nil
+}
+
+func (g *aes256GcmMultiKeyGhostifyer) latestKeyID() (string, error) {
+	encKey, err := g.keys.Latest(context.TODO())
+	if err != nil {
+		return "", err
+	}
+
+	return aes256GcmKeyID(encKey), nil
+}
+
+func (g *aes256GcmMultiKeyGhostifyer) Rewrap(s string) (string, bool, error) {
+	encKey, err := g.keys.Latest(context.TODO())
+	if err != nil {
+		return "", false, err
 	}
 
-	return &GhostString{Namespace: unParts.namespace, Str: string(plainBytes)}, nil
+	allKeys, err := g.keys.All(context.TODO())
+	if err != nil {
+		return "", false, err
+	}
+
+	return aes256GcmRewrap(encKey, allKeys, s, g.opts)
+}
+
+func (g *aes256GcmMultiKeyGhostifyer) options() *ghostifyerOptions {
+	return g.opts
 }
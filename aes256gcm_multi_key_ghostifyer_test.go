@@ -148,3 +148,48 @@ func TestAES256GCMultiKeyGhostifyer(t *testing.T) {
 		})
 	}
 }
+
+func TestAES256GCMultiKeyGhostifyer_KeyInfo(t *testing.T) {
+	r := require.New(t)
+
+	ks, err := ghoststring.NewKeyStore(
+		"test.keyinfo",
+		[]*ghoststring.TimestampedKey{
+			{Timestamp: 1661351742000, Key: "grim cereal tardy octopus"},
+			{Timestamp: 1661351759000, Key: "correct horse battery staple"},
+		},
+	)
+	r.Nil(err)
+
+	gh := ghoststring.NewAES256GCMMultiKeyGhostifyer("test.keyinfo", ks)
+
+	old, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.keyinfo", "grim cereal tardy octopus")
+	r.Nil(err)
+
+	oldStr, err := old.Ghostify(&ghoststring.GhostString{Namespace: "test.keyinfo", Str: "stump"})
+	r.Nil(err)
+
+	un, err := gh.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(oldStr)
+	r.Nil(err)
+	r.Equal("stump", un.GhostString.Str)
+	r.Equal(1, un.KeyIndex)
+	r.Equal(int64(1661351742000), un.KeyTimestamp.UnixMilli())
+	r.False(un.Latest)
+	r.NotEmpty(un.KeyID)
+
+	oldUn, err := old.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(oldStr)
+	r.Nil(err)
+	r.Equal(un.KeyID, oldUn.KeyID)
+	r.True(oldUn.Latest)
+	r.True(oldUn.KeyTimestamp.IsZero())
+
+	latestStr, err := gh.Ghostify(&ghoststring.GhostString{Namespace: "test.keyinfo", Str: "sapling"})
+	r.Nil(err)
+
+	un, err = gh.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(latestStr)
+	r.Nil(err)
+	r.Equal(0, un.KeyIndex)
+	r.Equal(int64(1661351759000), un.KeyTimestamp.UnixMilli())
+	r.True(un.Latest)
+	r.NotEqual(oldUn.KeyID, un.KeyID)
+}
@@ -1,8 +1,7 @@
 package ghoststring
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"context"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -11,64 +10,59 @@ import (
 // NewAES256GCMSingleKeyGhostifyer creates a Ghostifyer with a
 // single key that uses AES-256-GCM encryption with nonce assigned
 // at the individual string level.
-func NewAES256GCMSingleKeyGhostifyer(namespace, key string) (Ghostifyer, error) {
+func NewAES256GCMSingleKeyGhostifyer(namespace, key string, opts ...GhostifyerOption) (Ghostifyer, error) {
 	keyBytes, err := newAES256GCMKey(namespace, key)
 	if err != nil {
 		return nil, err
 	}
 
 	return &aes256GcmSingleKeyGhostifyer{
-		ns:  namespace,
-		key: keyBytes,
+		ns:   namespace,
+		key:  keyBytes,
+		opts: newGhostifyerOptions(opts),
 	}, nil
 }
 
 type aes256GcmSingleKeyGhostifyer struct {
-	ns  string
-	key []byte
+	ns   string
+	key  []byte
+	opts *ghostifyerOptions
 }
 
 func (g *aes256GcmSingleKeyGhostifyer) Namespace() string { return g.ns }
 
 func (g *aes256GcmSingleKeyGhostifyer) Ghostify(gs *GhostString) (string, error) {
+	return g.GhostifyWithHeader(gs, nil)
+}
+
+func (g *aes256GcmSingleKeyGhostifyer) GhostifyWithHeader(gs *GhostString, header Header) (string, error) {
 	if strings.TrimSpace(string(g.key)) == "" {
 		return "", errors.Wrap(Err, "invalid key")
 	}
 
-	if gs == nil || !gs.IsValid() {
+	if !g.opts.shouldGhostify(gs) {
 		return "", nil
 	}
 
-	nonce := make([]byte, Nonce)
-	if _, err := rand.Read(nonce); err != nil {
-		return "", err
-	}
+	return aes256GcmGhostify(g.key, gs, header, g.opts)
+}
 
-	encBytes, err := aes256GcmEncrypt(g.key, nonce, gs.Str)
+func (g *aes256GcmSingleKeyGhostifyer) Unghostify(s string) (*GhostString, error) {
+	un, err := g.UnghostifyDetailed(s)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	b64Value := base64.StdEncoding.EncodeToString(
-		append(
-			append(
-				nonce,
-				[]byte(gs.Namespace+NamespaceSeparator)...,
-			),
-			encBytes...,
-		),
-	)
-
-	return Prefix + b64Value, nil
+	return un.GhostString, nil
 }
 
-func (g *aes256GcmSingleKeyGhostifyer) Unghostify(s string) (*GhostString, error) {
+func (g *aes256GcmSingleKeyGhostifyer) UnghostifyDetailed(s string) (*Unghostified, error) {
 	if strings.TrimSpace(string(g.key)) == "" {
 		return nil, errors.Wrap(Err, "invalid key")
 	}
 
-	if s == Prefix || s == "" {
-		return &GhostString{}, nil
+	if isEmptyGhostified(s) {
+		return &Unghostified{GhostString: &GhostString{}, Header: Header{}}, nil
 	}
 
 	unParts, err := toUnghostifyParts(s)
@@ -76,10 +70,41 @@ func (g *aes256GcmSingleKeyGhostifyer) Unghostify(s string) (*GhostString, error
 		return nil, err
 	}
 
-	plainBytes, err := aes256GcmDecrypt(g.key, unParts.nonce, unParts.opaque)
+	plainText, _, err := aes256GcmOpen([][]byte{g.key}, unParts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &GhostString{Namespace: unParts.namespace, Str: string(plainBytes)}, nil
+	un, err := aes256GcmVerify(unParts, plainText, aes256GcmKeyID(g.key), g.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	un.Latest = true
+
+	return un, nil
+}
+
+func (g *aes256GcmSingleKeyGhostifyer) streamKeys(context.Context) ([]byte, [][]byte, error) {
+	if strings.TrimSpace(string(g.key)) == "" {
+		return nil, nil, errors.Wrap(Err, "invalid key")
+	}
+
+	return g.key, [][]byte{g.key}, nil
+}
+
+func (g *aes256GcmSingleKeyGhostifyer) latestKeyID() (string, error) {
+	return aes256GcmKeyID(g.key), nil
+}
+
+func (g *aes256GcmSingleKeyGhostifyer) Rewrap(s string) (string, bool, error) {
+	if strings.TrimSpace(string(g.key)) == "" {
+		return "", false, errors.Wrap(Err, "invalid key")
+	}
+
+	return aes256GcmRewrap(g.key, [][]byte{g.key}, s, g.opts)
+}
+
+func (g *aes256GcmSingleKeyGhostifyer) options() *ghostifyerOptions {
+	return g.opts
 }
@@ -0,0 +1,135 @@
+package ghoststring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// AgentSocketEnv is the environment variable in which the path
+	// of the Unix socket of a running `ghoststring agent` is found
+	// when not given to NewAgentGhostifyer.
+	AgentSocketEnv = "GHOSTSTRING_AGENT_SOCK"
+
+	agentTimeout = 30 * time.Second
+)
+
+// agentGhostifyer ghostifies and unghostifies via the HTTP API of a
+// `ghoststring agent` or `ghoststring serve` listening on a Unix
+// socket, so that keys are only held by the agent.
+type agentGhostifyer struct {
+	namespace string
+	client    *http.Client
+}
+
+type agentGhostStringBody struct {
+	GhostString string `json:"ghoststring"`
+}
+
+// NewAgentGhostifyer creates a Ghostifyer for the namespace that
+// uses the agent listening on the Unix socket at socketPath, or at
+// the path in AgentSocketEnv if empty.
+func NewAgentGhostifyer(namespace, socketPath string) (Ghostifyer, error) {
+	if err := validateNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	if socketPath == "" {
+		socketPath = os.Getenv(AgentSocketEnv)
+	}
+
+	if socketPath == "" {
+		return nil, errors.Wrapf(Err, "no agent socket given or set in %[1]v", AgentSocketEnv)
+	}
+
+	return &agentGhostifyer{
+		namespace: namespace,
+		client: &http.Client{
+			Timeout: agentTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}, nil
+}
+
+func (g *agentGhostifyer) Namespace() string {
+	return g.namespace
+}
+
+func (g *agentGhostifyer) Ghostify(gs *GhostString) (string, error) {
+	return g.GhostifyWithHeader(gs, nil)
+}
+
+func (g *agentGhostifyer) GhostifyWithHeader(gs *GhostString, header Header) (string, error) {
+	if gs == nil {
+		return "", errors.Wrap(Err, "nil GhostString")
+	}
+
+	if !gs.IsValid() {
+		return "", nil
+	}
+
+	fields := NewGhostStringFields(gs, header)
+
+	body := &agentGhostStringBody{}
+	if err := g.post("/encrypt", &fields, body); err != nil {
+		return "", err
+	}
+
+	return body.GhostString, nil
+}
+
+func (g *agentGhostifyer) Unghostify(s string) (*GhostString, error) {
+	un, err := g.UnghostifyDetailed(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return un.GhostString, nil
+}
+
+func (g *agentGhostifyer) UnghostifyDetailed(s string) (*Unghostified, error) {
+	if isEmptyGhostified(s) {
+		return &Unghostified{GhostString: &GhostString{}, Header: Header{}}, nil
+	}
+
+	fields := &GhostStringFields{}
+	if err := g.post("/decrypt", &agentGhostStringBody{GhostString: s}, fields); err != nil {
+		return nil, err
+	}
+
+	return &Unghostified{GhostString: fields.GhostString(), Header: fields.Header}, nil
+}
+
+func (g *agentGhostifyer) post(path string, reqBody, respBody any) error {
+	reqBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	resp, err := g.client.Post("http://agent"+path, "application/json", bytes.NewReader(reqBytes))
+	if err != nil {
+		return errors.Wrapf(Err, "agent unavailable: %[1]v", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		errBody := map[string]string{}
+		_ = json.NewDecoder(resp.Body).Decode(&errBody)
+
+		return errors.Wrapf(Err, "agent responded %[1]v: %[2]v", resp.StatusCode, errBody["error"])
+	}
+
+	return json.NewDecoder(resp.Body).Decode(respBody)
+}
@@ -0,0 +1,144 @@
+package ghoststring_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAgentGhostifyer(t *testing.T) {
+	r := require.New(t)
+
+	t.Setenv(ghoststring.AgentSocketEnv, "")
+
+	_, err := ghoststring.NewAgentGhostifyer("test.agent", "")
+	r.ErrorIs(err, ghoststring.Err)
+
+	_, err = ghoststring.NewAgentGhostifyer("!", "/nowhere.sock")
+	r.ErrorIs(err, ghoststring.Err)
+
+	t.Setenv(ghoststring.AgentSocketEnv, filepath.Join(t.TempDir(), "missing.sock"))
+
+	gh, err := ghoststring.NewAgentGhostifyer("test.agent", "")
+	r.Nil(err)
+	r.Equal("test.agent", gh.Namespace())
+
+	_, err = gh.Ghostify(&ghoststring.GhostString{Namespace: "test.agent", Str: "anyone home"})
+	r.ErrorIs(err, ghoststring.Err)
+
+	_, err = gh.Ghostify(nil)
+	r.ErrorIs(err, ghoststring.Err)
+
+	gs, err := gh.Unghostify("")
+	r.Nil(err)
+	r.Equal("", gs.Str)
+}
+
+func TestAgentGhostifyer(t *testing.T) {
+	r := require.New(t)
+
+	backing, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.agent", "secret agent")
+	r.Nil(err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/encrypt", func(w http.ResponseWriter, req *http.Request) {
+		fields := &ghoststring.GhostStringFields{}
+		if err := json.NewDecoder(req.Body).Decode(fields); err != nil {
+			http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+
+		if fields.Namespace != "test.agent" {
+			http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+
+		s, err := backing.(ghoststring.HeaderGhostifyer).GhostifyWithHeader(fields.GhostString(), fields.Header)
+		if err != nil {
+			http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"ghoststring": s})
+	})
+	mux.HandleFunc("/decrypt", func(w http.ResponseWriter, req *http.Request) {
+		body := map[string]string{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+
+		un, err := backing.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(body["ghoststring"])
+		if err != nil {
+			http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+
+		fields := ghoststring.NewGhostStringFields(un.GhostString, un.UserHeader())
+		_ = json.NewEncoder(w).Encode(&fields)
+	})
+
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	r.Nil(err)
+
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Listener = listener
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	gh, err := ghoststring.NewAgentGhostifyer("test.agent", socketPath)
+	r.Nil(err)
+
+	issuedAt := time.UnixMilli(1661351759000)
+
+	s, err := gh.(ghoststring.HeaderGhostifyer).GhostifyWithHeader(
+		&ghoststring.GhostString{
+			Namespace: "test.agent",
+			Str:       "licensed to ghostify",
+			MaxAge:    time.Hour,
+			IssuedAt:  issuedAt,
+		},
+		ghoststring.Header{ghoststring.HeaderPurpose: "testing"},
+	)
+	r.Nil(err)
+	r.Contains(s, ghoststring.Prefix)
+
+	local, err := backing.Unghostify(s)
+	r.ErrorIs(err, ghoststring.ErrExpired)
+	r.Nil(local)
+
+	live, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+		"test.agent",
+		"secret agent",
+		ghoststring.WithClock(func() time.Time { return issuedAt }),
+	)
+	r.Nil(err)
+
+	un, err := live.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(s)
+	r.Nil(err)
+	r.Equal("licensed to ghostify", un.GhostString.Str)
+	r.Equal(ghoststring.Header{ghoststring.HeaderPurpose: "testing"}, un.UserHeader())
+
+	s, err = gh.Ghostify(&ghoststring.GhostString{Namespace: "test.agent", Str: "shaken, not stirred"})
+	r.Nil(err)
+
+	gs, err := gh.Unghostify(s)
+	r.Nil(err)
+	r.Equal("test.agent", gs.Namespace)
+	r.Equal("shaken, not stirred", gs.Str)
+
+	_, err = gh.Ghostify(&ghoststring.GhostString{Namespace: "test.agent.other", Str: "not mine"})
+	r.ErrorIs(err, ghoststring.Err)
+
+	_, err = gh.Unghostify("👻:bm90IGEgcmVhbCB2YWx1ZQ==")
+	r.ErrorIs(err, ghoststring.Err)
+}
@@ -0,0 +1,170 @@
+// Command ghoststring-rewrap-sql rewraps the ghostified values in
+// columns of a SQLite database table with the latest key of each
+// namespace, as for key rotation. It is separate from the
+// ghoststring command so that only it includes the "sqlite" driver.
+// Other databases may use ghoststring.RewrapSQL with their own
+// driver.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/rstudio/ghoststring"
+	_ "modernc.org/sqlite"
+)
+
+// rewrapConfig is the subset of the config of `ghoststring serve`
+// that configures the keys of each namespace.
+type rewrapConfig struct {
+	Namespaces map[string]*rewrapNamespaceConfig `json:"namespaces"`
+}
+
+// rewrapNamespaceConfig is the configuration of a namespace, the
+// keys of which are read from the environment as described by
+// ghoststring.EnvKeyStoreKeyPrefix when not given.
+type rewrapNamespaceConfig struct {
+	Keys []*ghoststring.TimestampedKey `json:"keys"`
+}
+
+func main() {
+	if err := rewrapSQL(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func rewrapSQL(args []string) error {
+	fs := flag.NewFlagSet("ghoststring-rewrap-sql", flag.ExitOnError)
+	namespacesFlag := fs.String("n", "default", "comma-separated namespaces, the keys of which are read from the environment")
+	configFlag := fs.String("c", "", "path to JSON config of namespaces as for serve, used instead of -n")
+	dsnFlag := fs.String("dsn", "", "SQLite data source name, such as a file path")
+	tableFlag := fs.String("table", "", "table to rewrap")
+	primaryKeyFlag := fs.String("pk", "id", "primary key column of the table")
+	columnsFlag := fs.String("columns", "", "comma-separated columns to rewrap")
+	batchFlag := fs.Int("batch", ghoststring.DefaultSQLRewrapBatchSize, "number of rows to read at a time")
+	concurrencyFlag := fs.Int("concurrency", 1, "number of rows to rewrap at once")
+	checkpointFlag := fs.String("checkpoint", "", "path to a file in which to save progress, from which to resume")
+	dryRunFlag := fs.Bool("dry-run", false, "count values that would be rewrapped without updating rows")
+	skipErrorsFlag := fs.Bool("skip-errors", false, "count values that fail to rewrap rather than stopping")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dsnFlag == "" || *tableFlag == "" || *columnsFlag == "" {
+		return errors.New("missing -dsn, -table, or -columns")
+	}
+
+	cfg, err := loadRewrapConfig(*namespacesFlag, *configFlag)
+	if err != nil {
+		return err
+	}
+
+	for namespace, nsCfg := range cfg.Namespaces {
+		var ks ghoststring.KeyStore
+
+		if len(nsCfg.Keys) > 0 {
+			ks, err = ghoststring.NewKeyStore(namespace, nsCfg.Keys)
+		} else {
+			ks, err = ghoststring.NewKeyStoreFromEnv(namespace, nil)
+		}
+
+		if err != nil {
+			return fmt.Errorf("namespace %[1]q: %[2]w", namespace, err)
+		}
+
+		if err := ghoststring.SetGhostifyer(ghoststring.NewAES256GCMMultiKeyGhostifyer(namespace, ks)); err != nil {
+			return err
+		}
+	}
+
+	db, err := sql.Open("sqlite", *dsnFlag)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = db.Close() }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	opts := []ghoststring.SQLRewrapOption{
+		ghoststring.WithSQLBatchSize(*batchFlag),
+		ghoststring.WithSQLConcurrency(*concurrencyFlag),
+		ghoststring.WithSQLProgress(logSQLRewrapProgress),
+	}
+
+	if *checkpointFlag != "" {
+		opts = append(opts, ghoststring.WithSQLCheckpoint(ghoststring.NewFileSQLCheckpoint(*checkpointFlag)))
+	}
+
+	if *dryRunFlag {
+		opts = append(opts, ghoststring.WithSQLDryRun())
+	}
+
+	if *skipErrorsFlag {
+		opts = append(opts, ghoststring.WithSQLSkipErrors())
+	}
+
+	columns := strings.Split(*columnsFlag, ",")
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+
+	progress, err := ghoststring.RewrapSQL(ctx, db, *tableFlag, *primaryKeyFlag, columns, opts...)
+	if progress != nil {
+		logSQLRewrapProgress(progress)
+	}
+
+	return err
+}
+
+func logSQLRewrapProgress(p *ghoststring.SQLRewrapProgress) {
+	log.Printf(
+		"checkpoint=%[1]q rows=%[2]v values=%[3]v rewrapped=%[4]v conflicts=%[5]v failed=%[6]v",
+		p.Checkpoint, p.Rows, p.Values, p.Rewrapped, p.Conflicts, p.Failed,
+	)
+}
+
+// loadRewrapConfig loads the config at configPath if given, or
+// otherwise configures the comma-separated namespaces to read their
+// keys from the environment.
+func loadRewrapConfig(namespaces, configPath string) (*rewrapConfig, error) {
+	cfg := &rewrapConfig{}
+
+	if configPath == "" {
+		cfg.Namespaces = map[string]*rewrapNamespaceConfig{}
+
+		for _, namespace := range strings.Split(namespaces, ",") {
+			cfg.Namespaces[strings.TrimSpace(namespace)] = &rewrapNamespaceConfig{}
+		}
+
+		return cfg, nil
+	}
+
+	f, err := os.Open(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	if err := json.NewDecoder(f).Decode(cfg); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Namespaces) == 0 {
+		return nil, errors.New("no namespaces configured")
+	}
+
+	return cfg, nil
+}
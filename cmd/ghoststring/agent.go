@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/rstudio/ghoststring"
+)
+
+// agent serves the same API as serve on a Unix socket that only
+// the current user may use. Unlike ssh-agent, it stays in the
+// foreground until interrupted, so it is expected to be run in the
+// background with clients pointed at the socket via
+// ghoststring.AgentSocketEnv.
+func agent(args []string) error {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(
+			fs.Output(),
+			"Usage: ghoststring agent [flags] &\n\n"+
+				"Serves until interrupted, so run it in the background and set %[1]v\n"+
+				"to the socket path in the environment of clients.\n\n",
+			ghoststring.AgentSocketEnv,
+		)
+		fs.PrintDefaults()
+	}
+
+	namespacesFlag := fs.String("n", "default", "comma-separated namespaces, the keys of which are read from the environment")
+	configFlag := fs.String("c", "", "path to JSON config of namespaces as for serve, used instead of -n")
+	socketFlag := fs.String("socket", "", "Unix socket path to listen on, which defaults to one in a new private directory")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !peerUIDSupported {
+		return fmt.Errorf("agent requires peer credentials, which are not supported on %[1]v", runtime.GOOS)
+	}
+
+	cfg, err := loadNamespacesConfig(*namespacesFlag, *configFlag)
+	if err != nil {
+		return err
+	}
+
+	uid := uint32(os.Getuid())
+
+	for _, nsCfg := range cfg.Namespaces {
+		nsCfg.BearerTokens = nil
+		nsCfg.UIDs = []uint32{uid}
+	}
+
+	gs, err := newGhostServer(cfg)
+	if err != nil {
+		return err
+	}
+
+	socketPath := *socketFlag
+
+	if socketPath == "" {
+		dir, err := os.MkdirTemp("", "ghoststring-agent-")
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		socketPath = filepath.Join(dir, "agent.sock")
+	}
+
+	listener, err := listenPrivate(socketPath)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("agent running in the foreground; set %[1]v=%[2]q for clients", ghoststring.AgentSocketEnv, socketPath)
+
+	return serveListener(gs, listener)
+}
+
+// listenPrivate listens on a Unix socket at the path that only the
+// current user may connect to. The socket is created in a new
+// private directory beside the path and moved into place once its
+// permissions are restricted, so that it is never accessible to
+// others, even briefly.
+func listenPrivate(socketPath string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(socketPath), ".ghoststring-agent-")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tmpPath := filepath.Join(dir, "agent.sock")
+
+	listener, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		_ = listener.Close()
+
+		return nil, err
+	}
+
+	if err := os.Rename(tmpPath, socketPath); err != nil {
+		_ = listener.Close()
+
+		return nil, err
+	}
+
+	return &privateListener{
+		Listener: listener,
+		addr:     &net.UnixAddr{Name: socketPath, Net: "unix"},
+	}, nil
+}
+
+// privateListener reports the path that a socket was moved to and
+// removes it when closed.
+type privateListener struct {
+	net.Listener
+
+	addr *net.UnixAddr
+}
+
+func (pl *privateListener) Addr() net.Addr {
+	return pl.addr
+}
+
+func (pl *privateListener) Close() error {
+	err := pl.Listener.Close()
+
+	_ = os.Remove(pl.addr.Name)
+
+	return err
+}
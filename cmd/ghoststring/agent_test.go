@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenPrivate(t *testing.T) {
+	r := require.New(t)
+
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "agent.sock")
+
+	listener, err := listenPrivate(socketPath)
+	r.Nil(err)
+	r.Equal(socketPath, listener.Addr().String())
+
+	fi, err := os.Stat(socketPath)
+	r.Nil(err)
+	r.Equal(os.FileMode(0600), fi.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	r.Nil(err)
+	r.Len(entries, 1)
+
+	go func() {
+		c, err := listener.Accept()
+		if err == nil {
+			_ = c.Close()
+		}
+	}()
+
+	c, err := net.Dial("unix", socketPath)
+	r.Nil(err)
+	r.Nil(c.Close())
+
+	r.Nil(listener.Close())
+
+	_, err = os.Stat(socketPath)
+	r.True(os.IsNotExist(err))
+}
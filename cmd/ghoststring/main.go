@@ -11,17 +11,46 @@ import (
 )
 
 func main() {
-	keyFlag := flag.String("k", "", "key to use in ghostifying")
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := serve(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		if err := agent(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	keyFlag := flag.String("k", "", "key to use in ghostifying, instead of the agent at "+ghoststring.AgentSocketEnv)
 	decryptFlag := flag.Bool("d", false, "decrypt input")
 	namespaceFlag := flag.String("n", "default", "namespace to use in ghostifying")
+	streamFlag := flag.Bool("s", false, "use the segmented stream form for large input")
 
 	flag.Parse()
 
-	ghostifyer, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(*namespaceFlag, *keyFlag)
+	ghostifyer, err := newGhostifyer(*namespaceFlag, *keyFlag)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if *streamFlag {
+		if err := ghoststring.SetGhostifyer(ghostifyer); err != nil {
+			log.Fatal(err)
+		}
+
+		if err := stream(*namespaceFlag, *decryptFlag); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
 	inStringBytes, err := io.ReadAll(os.Stdin)
 	if err != nil {
 		log.Fatal(err)
@@ -50,3 +79,37 @@ func main() {
 
 	fmt.Fprint(os.Stdout, encString)
 }
+
+// newGhostifyer uses the key if given, or otherwise the agent, so
+// that the key need not be passed on the command line.
+func newGhostifyer(namespace, key string) (ghoststring.Ghostifyer, error) {
+	if key == "" && os.Getenv(ghoststring.AgentSocketEnv) != "" {
+		return ghoststring.NewAgentGhostifyer(namespace, "")
+	}
+
+	return ghoststring.NewAES256GCMSingleKeyGhostifyer(namespace, key)
+}
+
+func stream(namespace string, decrypt bool) error {
+	if decrypt {
+		gr, err := ghoststring.NewGhostReader(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(os.Stdout, gr)
+
+		return err
+	}
+
+	gw, err := ghoststring.NewGhostWriter(os.Stdout, namespace)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(gw, os.Stdin); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
@@ -0,0 +1,38 @@
+//go:build darwin || freebsd
+
+package main
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+const peerUIDSupported = true
+
+// peerUID returns the uid of the process connected via a Unix
+// socket.
+func peerUID(c net.Conn) (uint32, bool) {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return 0, false
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+
+	var (
+		cred    *unix.Xucred
+		credErr error
+	)
+
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	}); err != nil || credErr != nil {
+		return 0, false
+	}
+
+	return cred.Uid, true
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+const peerUIDSupported = true
+
+// peerUID returns the uid of the process connected via a Unix
+// socket.
+func peerUID(c net.Conn) (uint32, bool) {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return 0, false
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+
+	var (
+		cred    *syscall.Ucred
+		credErr error
+	)
+
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil || credErr != nil {
+		return 0, false
+	}
+
+	return cred.Uid, true
+}
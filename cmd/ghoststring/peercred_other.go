@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import (
+	"net"
+)
+
+const peerUIDSupported = false
+
+// peerUID is not supported on this platform, so clients must use
+// bearer tokens and the agent refuses to start.
+func peerUID(net.Conn) (uint32, bool) {
+	return 0, false
+}
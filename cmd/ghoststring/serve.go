@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+)
+
+const (
+	serveMaxBodySize = 16 * 1024 * 1024
+	serveProbe       = "ready"
+)
+
+type serveConfig struct {
+	Namespaces   map[string]*serveNamespaceConfig `json:"namespaces"`
+	Translations []ghoststring.TranslationRule    `json:"translations"`
+}
+
+// serveNamespaceConfig is the configuration of a namespace, the
+// keys of which are read from the environment as described by
+// ghoststring.EnvKeyStoreKeyPrefix when not given. Clients must
+// present one of the bearer tokens or connect via the Unix socket
+// as one of the uids.
+type serveNamespaceConfig struct {
+	Keys         []*ghoststring.TimestampedKey `json:"keys"`
+	BearerTokens []string                      `json:"bearer_tokens"`
+	UIDs         []uint32                      `json:"uids"`
+
+	ghostifyer serveGhostifyer
+}
+
+// serveGhostifyer is a Ghostifyer that carries the header of each
+// value through encryption and decryption.
+type serveGhostifyer interface {
+	ghoststring.Ghostifyer
+	ghoststring.HeaderGhostifyer
+	ghoststring.DetailedUnghostifyer
+}
+
+type peerUIDContextKey struct{}
+
+type ghostServer struct {
+	namespaces map[string]*serveNamespaceConfig
+	translator *ghoststring.Translator
+	mux        *http.ServeMux
+}
+
+type ghostStringBody struct {
+	GhostString string `json:"ghoststring"`
+}
+
+type rewrapBody struct {
+	GhostString string `json:"ghoststring"`
+	Changed     bool   `json:"changed"`
+}
+
+type translateBody struct {
+	GhostString string `json:"ghoststring"`
+	Namespace   string `json:"namespace"`
+}
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	configFlag := fs.String("c", "", "path to JSON config of namespaces and their clients")
+	listenFlag := fs.String("listen", "127.0.0.1:8877", "TCP address to listen on")
+	socketFlag := fs.String("socket", "", "Unix socket path to listen on instead of -listen")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadServeConfig(*configFlag)
+	if err != nil {
+		return err
+	}
+
+	gs, err := newGhostServer(cfg)
+	if err != nil {
+		return err
+	}
+
+	network, addr := "tcp", *listenFlag
+	if *socketFlag != "" {
+		network, addr = "unix", *socketFlag
+	}
+
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+
+	return serveListener(gs, listener)
+}
+
+// serveListener serves the handler until interrupted.
+func serveListener(handler http.Handler, listener net.Listener) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			if uid, ok := peerUID(c); ok {
+				return context.WithValue(ctx, peerUIDContextKey{}, uid)
+			}
+
+			return ctx
+		},
+	}
+
+	go func() {
+		defer stop()
+
+		log.Printf("listening at %[1]v %[2]q", listener.Addr().Network(), listener.Addr().String())
+
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("OH NO: %[1]v", err)
+		}
+	}()
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return srv.Shutdown(shutdownCtx)
+}
+
+func loadServeConfig(path string) (*serveConfig, error) {
+	if path == "" {
+		return nil, errors.New("missing config path")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	cfg := &serveConfig{}
+	if err := json.NewDecoder(f).Decode(cfg); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Namespaces) == 0 {
+		return nil, errors.New("no namespaces configured")
+	}
+
+	return cfg, nil
+}
+
+// loadNamespacesConfig loads the config at configPath if given, or
+// otherwise configures the comma-separated namespaces to read their
+// keys from the environment.
+func loadNamespacesConfig(namespaces, configPath string) (*serveConfig, error) {
+	if configPath != "" {
+		return loadServeConfig(configPath)
+	}
+
+	cfg := &serveConfig{Namespaces: map[string]*serveNamespaceConfig{}}
+
+	for _, namespace := range strings.Split(namespaces, ",") {
+		cfg.Namespaces[strings.TrimSpace(namespace)] = &serveNamespaceConfig{}
+	}
+
+	return cfg, nil
+}
+
+func newGhostServer(cfg *serveConfig) (*ghostServer, error) {
+	if err := configureGhostifyers(cfg); err != nil {
+		return nil, err
+	}
+
+	gs := &ghostServer{namespaces: cfg.Namespaces, mux: http.NewServeMux()}
+
+	gs.translator = &ghoststring.Translator{
+		Rules:  cfg.Translations,
+		Audit:  auditTranslation,
+		Lookup: gs.lookupGhostifyer,
+	}
+
+	gs.mux.HandleFunc("/healthcheck", gs.handleHealthcheck)
+	gs.mux.HandleFunc("/readiness", gs.handleReadiness)
+	gs.mux.HandleFunc("/encrypt", gs.handleEncrypt)
+	gs.mux.HandleFunc("/decrypt", gs.handleDecrypt)
+	gs.mux.HandleFunc("/rewrap", gs.handleRewrap)
+	gs.mux.HandleFunc("/translate", gs.handleTranslate)
+
+	return gs, nil
+}
+
+// configureGhostifyers creates the Ghostifyer of each namespace.
+func configureGhostifyers(cfg *serveConfig) error {
+	for namespace, nsCfg := range cfg.Namespaces {
+		var (
+			ks  ghoststring.KeyStore
+			err error
+		)
+
+		if len(nsCfg.Keys) > 0 {
+			ks, err = ghoststring.NewKeyStore(namespace, nsCfg.Keys)
+		} else {
+			ks, err = ghoststring.NewKeyStoreFromEnv(namespace, nil)
+		}
+
+		if err != nil {
+			return fmt.Errorf("namespace %[1]q: %[2]w", namespace, err)
+		}
+
+		nsCfg.ghostifyer = ghoststring.NewAES256GCMMultiKeyGhostifyer(namespace, ks).(serveGhostifyer)
+	}
+
+	return nil
+}
+
+func (gs *ghostServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Server", "ghoststring/0")
+
+	if req.URL.Path != "/healthcheck" && req.URL.Path != "/readiness" && req.Method != http.MethodPost {
+		writeServeError(w, http.StatusMethodNotAllowed, "not like this")
+		return
+	}
+
+	gs.mux.ServeHTTP(w, req)
+}
+
+func (gs *ghostServer) handleHealthcheck(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleReadiness checks that every namespace is able to ghostify
+// and unghostify with its keys.
+func (gs *ghostServer) handleReadiness(w http.ResponseWriter, _ *http.Request) {
+	for namespace, nsCfg := range gs.namespaces {
+		s, err := nsCfg.ghostifyer.Ghostify(&ghoststring.GhostString{Namespace: namespace, Str: serveProbe})
+		if err == nil {
+			_, err = nsCfg.ghostifyer.Unghostify(s)
+		}
+
+		if err != nil {
+			writeServeError(w, http.StatusServiceUnavailable, fmt.Sprintf("namespace %[1]q not ready", namespace))
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (gs *ghostServer) handleEncrypt(w http.ResponseWriter, req *http.Request) {
+	body := &ghoststring.GhostStringFields{}
+	if !readServeBody(w, req, body) {
+		return
+	}
+
+	nsCfg, ok := gs.authorize(w, req, body.Namespace)
+	if !ok {
+		return
+	}
+
+	s, err := nsCfg.ghostifyer.GhostifyWithHeader(body.GhostString(), body.Header)
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeServeJSON(w, &ghostStringBody{GhostString: s})
+}
+
+func (gs *ghostServer) handleDecrypt(w http.ResponseWriter, req *http.Request) {
+	un, ok := gs.unghostify(w, req)
+	if !ok {
+		return
+	}
+
+	body := ghoststring.NewGhostStringFields(un.GhostString, un.UserHeader())
+
+	writeServeJSON(w, &body)
+}
+
+// handleRewrap re-ghostifies a value with the latest key of its
+// namespace if it was ghostified with any other, retaining its
+// issue time, max age, and header.
+func (gs *ghostServer) handleRewrap(w http.ResponseWriter, req *http.Request) {
+	body := &ghostStringBody{}
+	if !readServeBody(w, req, body) {
+		return
+	}
+
+	nsCfg, ok := gs.authorizeValue(w, req, body.GhostString)
+	if !ok {
+		return
+	}
+
+	s, changed, err := nsCfg.ghostifyer.(ghoststring.Rewrapper).Rewrap(body.GhostString)
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeServeJSON(w, &rewrapBody{GhostString: s, Changed: changed})
+}
+
+// handleTranslate re-ghostifies a value in another namespace as
+// allowed by the configured translations, for clients authorized
+// for the namespace of the value.
+func (gs *ghostServer) handleTranslate(w http.ResponseWriter, req *http.Request) {
+	body := &translateBody{}
+	if !readServeBody(w, req, body) {
+		return
+	}
+
+	if _, ok := gs.authorizeValue(w, req, body.GhostString); !ok {
+		return
+	}
+
+	s, err := gs.translator.Translate(body.GhostString, body.Namespace)
+	if errors.Is(err, ghoststring.ErrNotAllowed) {
+		writeServeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeServeJSON(w, &ghostStringBody{GhostString: s})
+}
+
+func (gs *ghostServer) lookupGhostifyer(namespace string) (ghoststring.Ghostifyer, bool) {
+	nsCfg, ok := gs.namespaces[namespace]
+	if !ok {
+		return nil, false
+	}
+
+	return nsCfg.ghostifyer, true
+}
+
+func auditTranslation(event *ghoststring.TranslationEvent) {
+	result := "ok"
+	if event.Err != nil {
+		result = event.Err.Error()
+	}
+
+	log.Printf(
+		"translate from=%[1]q to=%[2]q token_id=%[3]q result=%[4]q",
+		event.From, event.To, event.TokenID, result,
+	)
+}
+
+func (gs *ghostServer) unghostify(w http.ResponseWriter, req *http.Request) (*ghoststring.Unghostified, bool) {
+	body := &ghostStringBody{}
+	if !readServeBody(w, req, body) {
+		return nil, false
+	}
+
+	nsCfg, ok := gs.authorizeValue(w, req, body.GhostString)
+	if !ok {
+		return nil, false
+	}
+
+	un, err := nsCfg.ghostifyer.UnghostifyDetailed(body.GhostString)
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	return un, true
+}
+
+// authorizeValue authorizes the client for the namespace of the
+// ghostified value, which is only authenticated once unghostified.
+func (gs *ghostServer) authorizeValue(w http.ResponseWriter, req *http.Request, s string) (*serveNamespaceConfig, bool) {
+	namespace, err := ghoststring.PeekNamespace(s)
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	return gs.authorize(w, req, namespace)
+}
+
+// authorize checks that the client presented a bearer token or
+// connected as a uid that is configured for the namespace.
+// Unconfigured namespaces are indistinguishable from those for
+// which the client is not authorized.
+func (gs *ghostServer) authorize(w http.ResponseWriter, req *http.Request, namespace string) (*serveNamespaceConfig, bool) {
+	token := ""
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	uid, hasUID := req.Context().Value(peerUIDContextKey{}).(uint32)
+
+	if token == "" && !hasUID {
+		writeServeError(w, http.StatusUnauthorized, "unauthorized")
+		return nil, false
+	}
+
+	nsCfg, ok := gs.namespaces[namespace]
+	if !ok {
+		writeServeError(w, http.StatusForbidden, "forbidden")
+		return nil, false
+	}
+
+	if token != "" {
+		for _, bearerToken := range nsCfg.BearerTokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(bearerToken)) == 1 {
+				return nsCfg, true
+			}
+		}
+	}
+
+	if hasUID {
+		for _, allowedUID := range nsCfg.UIDs {
+			if uid == allowedUID {
+				return nsCfg, true
+			}
+		}
+	}
+
+	writeServeError(w, http.StatusForbidden, "forbidden")
+
+	return nil, false
+}
+
+func readServeBody(w http.ResponseWriter, req *http.Request, v any) bool {
+	defer func() { _ = req.Body.Close() }()
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, serveMaxBodySize)).Decode(v); err != nil {
+		writeServeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	return true
+}
+
+func writeServeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("OH NO: %[1]v", err)
+	}
+}
+
+func writeServeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": msg}); err != nil {
+		log.Printf("OH NO: %[1]v", err)
+	}
+}
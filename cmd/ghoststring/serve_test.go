@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGhostServer(t *testing.T) *ghostServer {
+	gs, err := newGhostServer(
+		&serveConfig{
+			Namespaces: map[string]*serveNamespaceConfig{
+				"test.serve": {
+					Keys:         []*ghoststring.TimestampedKey{{Timestamp: 1661351759000, Key: "serve it up"}},
+					BearerTokens: []string{"open sesame"},
+					UIDs:         []uint32{4242},
+				},
+				"test.serve.other": {
+					Keys:         []*ghoststring.TimestampedKey{{Timestamp: 1661351759000, Key: "not yours"}},
+					BearerTokens: []string{"someone else"},
+				},
+			},
+		},
+	)
+	require.Nil(t, err)
+
+	return gs
+}
+
+func serveTestRequest(gs *ghostServer, method, path, token string, uid *uint32, body any) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(string(b)))
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if uid != nil {
+		req = req.WithContext(context.WithValue(req.Context(), peerUIDContextKey{}, *uid))
+	}
+
+	rec := httptest.NewRecorder()
+	gs.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestGhostServer(t *testing.T) {
+	gs := newTestGhostServer(t)
+
+	allowedUID := uint32(4242)
+	otherUID := uint32(1337)
+
+	fields := &ghoststring.GhostStringFields{
+		Namespace: "test.serve",
+		Str:       "over the counter",
+		TokenID:   "ticket",
+		Header:    ghoststring.Header{ghoststring.HeaderPurpose: "testing"},
+	}
+
+	t.Run("health", func(t *testing.T) {
+		r := require.New(t)
+
+		for _, path := range []string{"/healthcheck", "/readiness"} {
+			rec := serveTestRequest(gs, http.MethodGet, path, "", nil, nil)
+			r.Equal(http.StatusOK, rec.Code)
+			r.Equal("ghoststring/0", rec.Header().Get("Server"))
+		}
+	})
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		uid   *uint32
+	}{
+		{name: "bearer token", token: "open sesame"},
+		{name: "peer uid", uid: &allowedUID},
+		{name: "wrong token with peer uid", token: "wrong", uid: &allowedUID},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+
+			rec := serveTestRequest(gs, http.MethodPost, "/encrypt", tc.token, tc.uid, fields)
+			r.Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+			encrypted := &ghostStringBody{}
+			r.Nil(json.Unmarshal(rec.Body.Bytes(), encrypted))
+			r.Contains(encrypted.GhostString, ghoststring.Prefix)
+
+			rec = serveTestRequest(gs, http.MethodPost, "/decrypt", tc.token, tc.uid, encrypted)
+			r.Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+			decrypted := &ghoststring.GhostStringFields{}
+			r.Nil(json.Unmarshal(rec.Body.Bytes(), decrypted))
+			r.Equal(fields.Namespace, decrypted.Namespace)
+			r.Equal(fields.Str, decrypted.Str)
+			r.Equal(fields.TokenID, decrypted.TokenID)
+			r.Equal(fields.Header, decrypted.Header)
+
+			rec = serveTestRequest(gs, http.MethodPost, "/rewrap", tc.token, tc.uid, encrypted)
+			r.Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+			rewrapped := &rewrapBody{}
+			r.Nil(json.Unmarshal(rec.Body.Bytes(), rewrapped))
+			r.False(rewrapped.Changed)
+			r.Equal(encrypted.GhostString, rewrapped.GhostString)
+		})
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		r := require.New(t)
+
+		rec := serveTestRequest(gs, http.MethodPost, "/encrypt", "", nil, fields)
+		r.Equal(http.StatusUnauthorized, rec.Code)
+		r.Contains(rec.Body.String(), `"error"`)
+
+		rec = serveTestRequest(gs, http.MethodPost, "/encrypt", "open sesame", nil, fields)
+		r.Equal(http.StatusOK, rec.Code)
+
+		encrypted := &ghostStringBody{}
+		r.Nil(json.Unmarshal(rec.Body.Bytes(), encrypted))
+
+		rec = serveTestRequest(gs, http.MethodPost, "/decrypt", "", nil, encrypted)
+		r.Equal(http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		r := require.New(t)
+
+		for _, tc := range []struct {
+			name      string
+			token     string
+			uid       *uint32
+			namespace string
+		}{
+			{name: "wrong token", token: "wrong", namespace: "test.serve"},
+			{name: "wrong uid", uid: &otherUID, namespace: "test.serve"},
+			{name: "other namespace", token: "open sesame", namespace: "test.serve.other"},
+			{name: "unconfigured namespace", token: "open sesame", namespace: "test.serve.nowhere"},
+		} {
+			rec := serveTestRequest(
+				gs, http.MethodPost, "/encrypt", tc.token, tc.uid,
+				&ghoststring.GhostStringFields{Namespace: tc.namespace, Str: "not for you"},
+			)
+			r.Equalf(http.StatusForbidden, rec.Code, tc.name)
+		}
+	})
+
+	t.Run("translation not allowed", func(t *testing.T) {
+		r := require.New(t)
+
+		rec := serveTestRequest(gs, http.MethodPost, "/encrypt", "open sesame", nil, fields)
+		r.Equal(http.StatusOK, rec.Code)
+
+		encrypted := &ghostStringBody{}
+		r.Nil(json.Unmarshal(rec.Body.Bytes(), encrypted))
+
+		rec = serveTestRequest(
+			gs, http.MethodPost, "/translate", "open sesame", nil,
+			&translateBody{GhostString: encrypted.GhostString, Namespace: "test.serve.other"},
+		)
+		r.Equal(http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		r := require.New(t)
+
+		rec := serveTestRequest(gs, http.MethodGet, "/encrypt", "open sesame", nil, nil)
+		r.Equal(http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		r := require.New(t)
+
+		rec := serveTestRequest(gs, http.MethodPost, "/nowhere", "open sesame", nil, fields)
+		r.Equal(http.StatusNotFound, rec.Code)
+	})
+}
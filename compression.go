@@ -0,0 +1,76 @@
+package ghoststring
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMaxDecompressedSize is the default limit on the size of
+	// a compressed value once decompressed. See
+	// WithMaxDecompressedSize.
+	DefaultMaxDecompressedSize = 16 * 1024 * 1024
+
+	compressionDeflate = "deflate"
+)
+
+// compressPayload compresses the payload if enabled and the result
+// is smaller, flagging the compression in the header.
+func compressPayload(payload []byte, header Header, opts *ghostifyerOptions) ([]byte, error) {
+	if !opts.compression {
+		return payload, nil
+	}
+
+	buf := &bytes.Buffer{}
+
+	fw, err := flate.NewWriter(buf, opts.compressionLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := fw.Write(payload); err != nil {
+		return nil, err
+	}
+
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+
+	if buf.Len() >= len(payload) {
+		return payload, nil
+	}
+
+	header[HeaderCompression] = compressionDeflate
+
+	return buf.Bytes(), nil
+}
+
+// decompressPayload decompresses the payload if flagged in the
+// header, refusing to produce more than the configured maximum.
+func decompressPayload(payload []byte, header Header, opts *ghostifyerOptions) ([]byte, error) {
+	compression, ok := header[HeaderCompression]
+	if !ok {
+		return payload, nil
+	}
+
+	if compression != compressionDeflate {
+		return nil, errors.Wrapf(Err, "unsupported compression %[1]q", compression)
+	}
+
+	fr := flate.NewReader(bytes.NewReader(payload))
+	defer func() { _ = fr.Close() }()
+
+	decompressed, err := io.ReadAll(io.LimitReader(fr, opts.maxDecompressedSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(Err, "invalid compressed value: %[1]v", err)
+	}
+
+	if int64(len(decompressed)) > opts.maxDecompressedSize {
+		return nil, errors.Wrapf(Err, "decompressed value exceeds %[1]v bytes", opts.maxDecompressedSize)
+	}
+
+	return decompressed, nil
+}
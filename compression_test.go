@@ -0,0 +1,67 @@
+package ghoststring_test
+
+import (
+	"compress/flate"
+	"strings"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostString_Compression(t *testing.T) {
+	r := require.New(t)
+
+	blob := `{"items":[` + strings.Repeat(`{"name":"widget","color":"blue"},`, 500) + `{}]}`
+
+	plain, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.compression", "squeeze")
+	r.Nil(err)
+
+	compressing, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+		"test.compression",
+		"squeeze",
+		ghoststring.WithCompression(flate.BestCompression),
+	)
+	r.Nil(err)
+
+	limited, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+		"test.compression",
+		"squeeze",
+		ghoststring.WithMaxDecompressedSize(int64(len(blob)-1)),
+	)
+	r.Nil(err)
+
+	gs := &ghoststring.GhostString{Namespace: "test.compression", Str: blob}
+
+	uncompressedStr, err := plain.Ghostify(gs)
+	r.Nil(err)
+
+	compressedStr, err := compressing.Ghostify(gs)
+	r.Nil(err)
+	r.Less(len(compressedStr), len(uncompressedStr)/4)
+
+	header, err := ghoststring.PeekHeader(compressedStr)
+	r.Nil(err)
+	r.Equal("deflate", header[ghoststring.HeaderCompression])
+
+	for _, gh := range []ghoststring.Ghostifyer{plain, compressing} {
+		un, err := gh.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(compressedStr)
+		r.Nil(err)
+		r.Equal(blob, un.GhostString.Str)
+		r.Nil(un.UserHeader())
+	}
+
+	_, err = limited.Unghostify(compressedStr)
+	r.ErrorIs(err, ghoststring.Err)
+
+	un, err := limited.Unghostify(uncompressedStr)
+	r.Nil(err)
+	r.Equal(blob, un.Str)
+
+	shortStr, err := compressing.Ghostify(&ghoststring.GhostString{Namespace: "test.compression", Str: "giddy"})
+	r.Nil(err)
+
+	header, err = ghoststring.PeekHeader(shortStr)
+	r.Nil(err)
+	r.NotContains(header, ghoststring.HeaderCompression)
+}
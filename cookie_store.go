@@ -0,0 +1,365 @@
+package ghoststring
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCookieMaxAge is the default max age of sessions stored
+	// by a CookieStore. See WithCookieMaxAge.
+	DefaultCookieMaxAge = 24 * time.Hour
+
+	// CookieChunkSize is the maximum size of the value of each
+	// cookie written by a CookieStore, which leaves room for the
+	// name and attributes within the 4096 bytes that browsers are
+	// required to support per cookie.
+	CookieChunkSize = 3840
+
+	cookieChunkSeparator = "_"
+
+	// cookieSessionPurpose is the HeaderPurpose of every stored
+	// session, which prevents other values in the namespace, such
+	// as tokens sealed via SealToken, from being loaded as sessions.
+	cookieSessionPurpose = "session"
+)
+
+// CookieStoreOption configures optional behavior of a CookieStore.
+type CookieStoreOption func(*cookieStoreOptions)
+
+type cookieStoreOptions struct {
+	template  http.Cookie
+	maxAge    time.Duration
+	maxChunks int
+}
+
+// WithCookieTemplate sets the Path, Domain, Secure, HttpOnly, and
+// SameSite attributes of the cookies written. The default is a
+// Secure, HttpOnly cookie with the "/" Path and SameSite lax mode.
+func WithCookieTemplate(template http.Cookie) CookieStoreOption {
+	return func(o *cookieStoreOptions) {
+		o.template = http.Cookie{
+			Path:     template.Path,
+			Domain:   template.Domain,
+			Secure:   template.Secure,
+			HttpOnly: template.HttpOnly,
+			SameSite: template.SameSite,
+		}
+	}
+}
+
+// WithCookieMaxAge sets the max age of sessions, which is used both
+// as the cookie max age and as the authenticated GhostString.MaxAge,
+// so that sessions expire even if a client keeps the cookies. The
+// default is DefaultCookieMaxAge.
+func WithCookieMaxAge(maxAge time.Duration) CookieStoreOption {
+	return func(o *cookieStoreOptions) {
+		if maxAge > 0 {
+			o.maxAge = maxAge
+		}
+	}
+}
+
+// WithCookieMaxChunks sets the maximum number of cookies of up to
+// CookieChunkSize each across which a session may be split. The
+// default is 1, i.e. no chunking.
+func WithCookieMaxChunks(maxChunks int) CookieStoreOption {
+	return func(o *cookieStoreOptions) {
+		if maxChunks > 0 {
+			o.maxChunks = maxChunks
+		}
+	}
+}
+
+// CookieStore stores sessions of type T as JSON that is ghostified
+// with the Ghostifyer registered for its namespace in one or more
+// cookies. Sessions are re-ghostified with the latest key when
+// loaded from cookies ghostified with an older key by the
+// Middleware.
+type CookieStore[T any] struct {
+	namespace string
+	name      string
+	opts      *cookieStoreOptions
+}
+
+type cookieStoreContextKey struct {
+	store any
+}
+
+// cookieSessionState is the state of a session as loaded, which
+// determines if the session must be saved.
+type cookieSessionState struct {
+	str   string
+	stale bool
+}
+
+// NewCookieStore creates a CookieStore for sessions in the namespace
+// stored in cookies with the given name, which is suffixed with
+// "_1", "_2", etc. for any chunks beyond the first.
+func NewCookieStore[T any](namespace, name string, opts ...CookieStoreOption) (*CookieStore[T], error) {
+	if err := validateNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	if err := (&http.Cookie{Name: name}).Valid(); err != nil {
+		return nil, errors.Wrapf(Err, "invalid cookie name %[1]q", name)
+	}
+
+	o := &cookieStoreOptions{
+		template: http.Cookie{
+			Path:     "/",
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		maxAge:    DefaultCookieMaxAge,
+		maxChunks: 1,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &CookieStore[T]{namespace: namespace, name: name, opts: o}, nil
+}
+
+// Load reads the session from the request cookies, returning a new
+// session if there are none.
+func (cs *CookieStore[T]) Load(r *http.Request) (*T, error) {
+	session, _, err := cs.load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Save writes the session to response cookies, expiring any chunks
+// of the request session that are no longer needed.
+func (cs *CookieStore[T]) Save(w http.ResponseWriter, r *http.Request, session *T) error {
+	sessionBytes, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	return cs.save(w, r, string(sessionBytes))
+}
+
+// Clear expires all cookies of the request session.
+func (cs *CookieStore[T]) Clear(w http.ResponseWriter, r *http.Request) {
+	cs.expireChunks(w, r, 0)
+}
+
+// Middleware loads the session into the request context, from
+// which it is available via FromContext, and saves the session
+// before the response is written if it was changed by the handler.
+// Sessions that cannot be loaded, such as those that have expired,
+// are replaced with a new session.
+func (cs *CookieStore[T]) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, state, err := cs.load(r)
+		if err != nil {
+			session = new(T)
+		}
+
+		sw := &sessionResponseWriter{
+			ResponseWriter: w,
+			save: func() error {
+				sessionBytes, err := json.Marshal(session)
+				if err != nil {
+					return err
+				}
+
+				if state != nil && !state.stale && string(sessionBytes) == state.str {
+					return nil
+				}
+
+				return cs.save(w, r, string(sessionBytes))
+			},
+		}
+
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), cookieStoreContextKey{cs}, session)))
+
+		sw.commit()
+	})
+}
+
+// FromContext returns the session loaded by the Middleware.
+func (cs *CookieStore[T]) FromContext(ctx context.Context) (*T, bool) {
+	session, ok := ctx.Value(cookieStoreContextKey{cs}).(*T)
+
+	return session, ok
+}
+
+func (cs *CookieStore[T]) load(r *http.Request) (*T, *cookieSessionState, error) {
+	s := ""
+
+	for i := 0; i < cs.opts.maxChunks; i++ {
+		c, err := r.Cookie(cs.chunkName(i))
+		if err != nil {
+			break
+		}
+
+		s += c.Value
+	}
+
+	if s == "" {
+		sessionBytes, err := json.Marshal(new(T))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return new(T), &cookieSessionState{str: string(sessionBytes)}, nil
+	}
+
+	un, err := metaUnghostify(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if un.GhostString.Namespace != cs.namespace {
+		return nil, nil, errors.Wrapf(Err, "session namespace %[1]q is not %[2]q", un.GhostString.Namespace, cs.namespace)
+	}
+
+	if purpose := un.Header[HeaderPurpose]; purpose != cookieSessionPurpose {
+		return nil, nil, errors.Wrapf(Err, "purpose %[1]q is not %[2]q", purpose, cookieSessionPurpose)
+	}
+
+	session := new(T)
+	if err := json.Unmarshal([]byte(un.GhostString.Str), session); err != nil {
+		return nil, nil, errors.Wrap(Err, "invalid session")
+	}
+
+	state := &cookieSessionState{str: un.GhostString.Str}
+
+	if ghostifyer, ok := getGhostifyer(cs.namespace); ok {
+		if ki, ok := ghostifyer.(keyIdentifier); ok {
+			keyID, err := ki.latestKeyID()
+			state.stale = err == nil && keyID != un.KeyID
+		}
+	}
+
+	return session, state, nil
+}
+
+func (cs *CookieStore[T]) save(w http.ResponseWriter, r *http.Request, str string) error {
+	ghostifyer, ok := getGhostifyer(cs.namespace)
+	if !ok {
+		return errors.Wrapf(Err, "no ghostifyer set for namespace %[1]q", cs.namespace)
+	}
+
+	s, err := ghostifyWithHeader(
+		ghostifyer,
+		&GhostString{Namespace: cs.namespace, Str: str, MaxAge: cs.opts.maxAge},
+		Header{HeaderPurpose: cookieSessionPurpose},
+	)
+	if err != nil {
+		return err
+	}
+
+	s, err = toURLSafe(s)
+	if err != nil {
+		return err
+	}
+
+	chunks := []string{}
+	for len(s) > CookieChunkSize {
+		chunks = append(chunks, s[:CookieChunkSize])
+		s = s[CookieChunkSize:]
+	}
+
+	chunks = append(chunks, s)
+
+	if len(chunks) > cs.opts.maxChunks {
+		return errors.Wrapf(Err, "session requires %[1]v cookies but at most %[2]v are allowed", len(chunks), cs.opts.maxChunks)
+	}
+
+	for i, chunk := range chunks {
+		c := cs.opts.template
+		c.Name = cs.chunkName(i)
+		c.Value = chunk
+		c.MaxAge = int(cs.opts.maxAge / time.Second)
+		c.Expires = time.Now().Add(cs.opts.maxAge)
+
+		http.SetCookie(w, &c)
+	}
+
+	cs.expireChunks(w, r, len(chunks))
+
+	return nil
+}
+
+func (cs *CookieStore[T]) expireChunks(w http.ResponseWriter, r *http.Request, from int) {
+	for i := from; i < cs.opts.maxChunks; i++ {
+		if _, err := r.Cookie(cs.chunkName(i)); err != nil {
+			continue
+		}
+
+		c := cs.opts.template
+		c.Name = cs.chunkName(i)
+		c.MaxAge = -1
+
+		http.SetCookie(w, &c)
+	}
+}
+
+func (cs *CookieStore[T]) chunkName(i int) string {
+	if i == 0 {
+		return cs.name
+	}
+
+	return cs.name + cookieChunkSeparator + strconv.Itoa(i)
+}
+
+// sessionResponseWriter saves the session before anything is
+// written, since cookies may not be set after that.
+type sessionResponseWriter struct {
+	http.ResponseWriter
+
+	save      func() error
+	committed bool
+	err       error
+}
+
+func (sw *sessionResponseWriter) WriteHeader(code int) {
+	sw.commit()
+
+	if sw.err != nil {
+		return
+	}
+
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *sessionResponseWriter) Write(b []byte) (int, error) {
+	sw.commit()
+
+	if sw.err != nil {
+		return 0, sw.err
+	}
+
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to access the underlying
+// http.ResponseWriter.
+func (sw *sessionResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+func (sw *sessionResponseWriter) commit() {
+	if sw.committed {
+		return
+	}
+
+	sw.committed = true
+
+	if sw.err = sw.save(); sw.err != nil {
+		http.Error(sw.ResponseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
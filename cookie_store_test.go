@@ -0,0 +1,213 @@
+package ghoststring_test
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+type cookieSession struct {
+	User   string `json:"user"`
+	Visits int    `json:"visits"`
+	Blob   string `json:"blob,omitempty"`
+}
+
+func sendWithCookies(handler http.Handler, cookies []*http.Cookie) (*httptest.ResponseRecorder, []*http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	jar := map[string]*http.Cookie{}
+	for _, c := range cookies {
+		jar[c.Name] = c
+	}
+
+	for _, c := range rec.Result().Cookies() {
+		if c.MaxAge < 0 {
+			delete(jar, c.Name)
+			continue
+		}
+
+		jar[c.Name] = c
+	}
+
+	next := []*http.Cookie{}
+	for _, c := range jar {
+		next = append(next, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+
+	return rec, next
+}
+
+func TestCookieStore(t *testing.T) {
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.cookies", "mountain high", ghoststring.WithClock(clock))
+	require.Nil(t, err)
+	require.Nil(t, ghoststring.SetGhostifyer(gh))
+
+	cs, err := ghoststring.NewCookieStore[cookieSession](
+		"test.cookies",
+		"session",
+		ghoststring.WithCookieMaxAge(time.Hour),
+		ghoststring.WithCookieMaxChunks(4),
+	)
+	require.Nil(t, err)
+
+	blob := ""
+
+	handler := cs.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, ok := cs.FromContext(r.Context())
+		if !ok {
+			http.Error(w, "no session", http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("peek") == "" {
+			session.User = "giddy"
+			session.Visits++
+			session.Blob = blob
+		}
+
+		fmt.Fprintf(w, "visits=%[1]v", session.Visits)
+	}))
+
+	t.Run("visits", func(t *testing.T) {
+		r := require.New(t)
+
+		rec, cookies := sendWithCookies(handler, nil)
+		r.Equal("visits=1", rec.Body.String())
+		r.Len(cookies, 1)
+		r.NotContains(cookies[0].Value, "giddy")
+
+		setCookie := rec.Result().Cookies()[0]
+		r.True(setCookie.HttpOnly)
+		r.True(setCookie.Secure)
+		r.Equal(3600, setCookie.MaxAge)
+
+		rec, cookies = sendWithCookies(handler, cookies)
+		r.Equal("visits=2", rec.Body.String())
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+
+		session, err := cs.Load(req)
+		r.Nil(err)
+		r.Equal(&cookieSession{User: "giddy", Visits: 2}, session)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		r := require.New(t)
+
+		peek := cs.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		rec, _ := sendWithCookies(peek, nil)
+		r.Equal(http.StatusNoContent, rec.Code)
+		r.Len(rec.Result().Cookies(), 0)
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		r := require.New(t)
+
+		blobBytes := make([]byte, 1500)
+		_, err := rand.Read(blobBytes)
+		r.Nil(err)
+
+		blob = hex.EncodeToString(blobBytes)
+		defer func() { blob = "" }()
+
+		rec, cookies := sendWithCookies(handler, nil)
+		r.Equal(http.StatusOK, rec.Code)
+		r.Len(cookies, 2)
+
+		for _, c := range rec.Result().Cookies() {
+			r.LessOrEqual(len(c.Value), ghoststring.CookieChunkSize)
+		}
+
+		blob = ""
+
+		rec, cookies = sendWithCookies(handler, cookies)
+		r.Equal("visits=2", rec.Body.String())
+		r.Len(cookies, 1)
+
+		blob = strings.Repeat(hex.EncodeToString(blobBytes), 4)
+
+		rec, _ = sendWithCookies(handler, cookies)
+		r.Equal(http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		r := require.New(t)
+
+		_, cookies := sendWithCookies(handler, nil)
+
+		now = now.Add(2 * time.Hour)
+		defer func() { now = now.Add(-2 * time.Hour) }()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+
+		_, err := cs.Load(req)
+		r.ErrorIs(err, ghoststring.ErrExpired)
+
+		rec, _ := sendWithCookies(handler, cookies)
+		r.Equal("visits=1", rec.Body.String())
+	})
+
+	t.Run("key rotation", func(t *testing.T) {
+		r := require.New(t)
+
+		_, cookies := sendWithCookies(handler, nil)
+
+		ks, err := ghoststring.NewKeyStore(
+			"test.cookies",
+			[]*ghoststring.TimestampedKey{
+				{Timestamp: 1661351759000, Key: "river deep"},
+				{Timestamp: 1661351742000, Key: "mountain high"},
+			},
+		)
+		r.Nil(err)
+
+		r.Nil(ghoststring.SetGhostifyer(ghoststring.NewAES256GCMMultiKeyGhostifyer("test.cookies", ks, ghoststring.WithClock(clock))))
+		defer func() { r.Nil(ghoststring.SetGhostifyer(gh)) }()
+
+		req := httptest.NewRequest(http.MethodGet, "/?peek=1", nil)
+		req.AddCookie(cookies[0])
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		r.Equal("visits=1", rec.Body.String())
+
+		rotated := rec.Result().Cookies()
+		r.Len(rotated, 1)
+		r.NotEqual(cookies[0].Value, rotated[0].Value)
+
+		_, err = gh.Unghostify(rotated[0].Value)
+		r.NotNil(err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		r := require.New(t)
+
+		_, err := ghoststring.NewCookieStore[cookieSession]("test.cookies", "bad name;")
+		r.ErrorIs(err, ghoststring.Err)
+
+		_, err = ghoststring.NewCookieStore[cookieSession]("!", "session")
+		r.ErrorIs(err, ghoststring.Err)
+	})
+}
@@ -0,0 +1,109 @@
+package ghoststring
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+const (
+	// ASCIIPrefix and URLPrefix are used in place of Prefix by
+	// EncodingASCII and EncodingURL respectively, for use where the
+	// emoji in Prefix is not safe. Each prefix records its Encoding
+	// so that it need not be guessed from the encoded value.
+	ASCIIPrefix = "gs1:"
+	URLPrefix   = "gs1u:"
+)
+
+// Encoding determines the prefix and base64 alphabet of the string
+// form of ghostified values. Values in any Encoding may be
+// unghostified regardless of the Encoding configured.
+type Encoding int
+
+const (
+	// EncodingStd is Prefix followed by standard padded base64,
+	// which is the default.
+	EncodingStd Encoding = iota
+	// EncodingASCII is ASCIIPrefix followed by standard padded
+	// base64, for storage that is not UTF-8 safe.
+	EncodingASCII
+	// EncodingURL is URLPrefix followed by unpadded URL-safe
+	// base64, which may be used in URLs, cookies, and HTTP headers
+	// without escaping.
+	EncodingURL
+)
+
+func (enc Encoding) encode(raw []byte) string {
+	switch enc {
+	case EncodingASCII:
+		return ASCIIPrefix + base64.StdEncoding.EncodeToString(raw)
+	case EncodingURL:
+		return URLPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	default:
+		return Prefix + base64.StdEncoding.EncodeToString(raw)
+	}
+}
+
+// decodeAnyEncoding decodes the string form of a ghostified value
+// in any Encoding, with or without its prefix.
+func decodeAnyEncoding(s string) ([]byte, error) {
+	if strings.HasPrefix(s, URLPrefix) {
+		return base64.RawURLEncoding.DecodeString(s[len(URLPrefix):])
+	}
+
+	s = trimGhostifiedPrefix(s)
+	s = strings.TrimRight(s, "=")
+
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// hasGhostifiedPrefix checks if the string begins with the prefix
+// of any Encoding.
+func hasGhostifiedPrefix(s string) bool {
+	return strings.HasPrefix(s, Prefix) ||
+		strings.HasPrefix(s, ASCIIPrefix) ||
+		strings.HasPrefix(s, URLPrefix)
+}
+
+// isEmptyGhostified checks if the string is the ghostified form of
+// an empty value, i.e. either empty or only a prefix.
+func isEmptyGhostified(s string) bool {
+	return s == "" || s == Prefix || s == ASCIIPrefix || s == URLPrefix
+}
+
+// toURLSafe re-encodes a ghostified string in EncodingURL, since
+// the default Encoding is not safe for URLs and cookies.
+func toURLSafe(s string) (string, error) {
+	raw, err := decodeGhostified(s)
+	if err != nil {
+		return "", err
+	}
+
+	return EncodingURL.encode(raw), nil
+}
+
+// encodingOf determines the Encoding of a ghostified string from
+// its prefix.
+func encodingOf(s string) Encoding {
+	switch {
+	case strings.HasPrefix(s, URLPrefix):
+		return EncodingURL
+	case strings.HasPrefix(s, ASCIIPrefix):
+		return EncodingASCII
+	}
+
+	return EncodingStd
+}
+
+func trimGhostifiedPrefix(s string) string {
+	for _, prefix := range []string{Prefix, ASCIIPrefix, URLPrefix} {
+		if strings.HasPrefix(s, prefix) {
+			return s[len(prefix):]
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,101 @@
+package ghoststring_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostString_Encoding(t *testing.T) {
+	ghostifyers := map[ghoststring.Encoding]ghoststring.Ghostifyer{}
+
+	for _, enc := range []ghoststring.Encoding{
+		ghoststring.EncodingStd,
+		ghoststring.EncodingASCII,
+		ghoststring.EncodingURL,
+	} {
+		gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+			"test.encoding",
+			"say it plainly",
+			ghoststring.WithEncoding(enc),
+			ghoststring.WithHeaders(ghoststring.Header{ghoststring.HeaderPurpose: "travel"}),
+		)
+		require.Nil(t, err)
+
+		ghostifyers[enc] = gh
+	}
+
+	for enc, gh := range ghostifyers {
+		r := require.New(t)
+
+		s, err := gh.Ghostify(&ghoststring.GhostString{Namespace: "test.encoding", Str: "??? & ~~~ / +++"})
+		r.Nil(err)
+
+		switch enc {
+		case ghoststring.EncodingStd:
+			r.True(strings.HasPrefix(s, ghoststring.Prefix))
+		case ghoststring.EncodingASCII:
+			r.True(strings.HasPrefix(s, ghoststring.ASCIIPrefix))
+			r.NotContains(s, "-")
+		case ghoststring.EncodingURL:
+			r.True(strings.HasPrefix(s, ghoststring.URLPrefix))
+			r.Equal(s, url.PathEscape(s))
+
+			query, err := url.ParseQuery("t=" + s)
+			r.Nil(err)
+			r.Equal(s, query.Get("t"))
+			r.NotContains(s, "=")
+		}
+
+		header, err := ghoststring.PeekHeader(s)
+		r.Nil(err)
+		r.Equal("travel", header[ghoststring.HeaderPurpose])
+
+		for _, other := range ghostifyers {
+			gs, err := other.Unghostify(s)
+			r.Nil(err)
+			r.Equal("??? & ~~~ / +++", gs.Str)
+		}
+	}
+
+	r := require.New(t)
+
+	r.Nil(ghoststring.SetGhostifyer(ghostifyers[ghoststring.EncodingURL]))
+
+	b, err := json.Marshal(&ghoststring.GhostString{Namespace: "test.encoding", Str: "roundabout"})
+	r.Nil(err)
+	r.Contains(string(b), ghoststring.URLPrefix)
+
+	fromJSON := &ghoststring.GhostString{}
+	r.Nil(json.Unmarshal(b, fromJSON))
+	r.Equal("roundabout", fromJSON.Str)
+
+	r.Nil(ghoststring.SetGhostifyer(ghostifyers[ghoststring.EncodingASCII]))
+
+	unambiguous := ""
+
+	for i := 0; i < 1000 && unambiguous == ""; i++ {
+		s, err := ghostifyers[ghoststring.EncodingASCII].Ghostify(&ghoststring.GhostString{Namespace: "test.encoding", Str: "ok"})
+		r.Nil(err)
+
+		if !strings.ContainsAny(s[len(ghoststring.ASCIIPrefix):], "+/=") {
+			unambiguous = s
+		}
+	}
+
+	r.NotEqual("", unambiguous)
+
+	fromASCII := &ghoststring.GhostString{}
+	r.Nil(fromASCII.UnmarshalText([]byte(unambiguous)))
+	r.Equal(unambiguous, fromASCII.String())
+
+	for _, prefix := range []string{ghoststring.ASCIIPrefix, ghoststring.URLPrefix} {
+		empty, err := ghostifyers[ghoststring.EncodingStd].Unghostify(prefix)
+		r.Nil(err)
+		r.Equal("", empty.Str)
+	}
+}
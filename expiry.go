@@ -0,0 +1,78 @@
+package ghoststring
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// setTimeLimitHeader records the issue time of a GhostString that
+// has any header, along with the max age of a time-limited
+// GhostString, in milliseconds, matching the resolution of
+// TimestampedKey.
+func setTimeLimitHeader(header Header, gs *GhostString, now time.Time) {
+	if gs.MaxAge <= 0 && len(header) == 0 {
+		return
+	}
+
+	issuedAt := gs.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = now
+	}
+
+	header[HeaderIssuedAt] = strconv.FormatInt(issuedAt.UnixMilli(), 10)
+
+	if gs.MaxAge > 0 {
+		header[HeaderMaxAge] = strconv.FormatInt(gs.MaxAge.Milliseconds(), 10)
+	}
+}
+
+// readTimeLimitHeader populates the issue time and max age of a
+// GhostString from an authenticated header, if present.
+func readTimeLimitHeader(gs *GhostString, header Header) error {
+	if rawIssuedAt, ok := header[HeaderIssuedAt]; ok {
+		issuedAtMillis, err := strconv.ParseInt(rawIssuedAt, 10, 64)
+		if err != nil {
+			return errors.Wrapf(Err, "invalid issue time %[1]q", rawIssuedAt)
+		}
+
+		gs.IssuedAt = time.UnixMilli(issuedAtMillis)
+	}
+
+	rawMaxAge, ok := header[HeaderMaxAge]
+	if !ok {
+		return nil
+	}
+
+	maxAgeMillis, err := strconv.ParseInt(rawMaxAge, 10, 64)
+	if err != nil {
+		return errors.Wrapf(Err, "invalid max age %[1]q", rawMaxAge)
+	}
+
+	if gs.IssuedAt.IsZero() {
+		return errors.Wrap(Err, "missing issue time")
+	}
+
+	gs.MaxAge = time.Duration(maxAgeMillis) * time.Millisecond
+
+	return nil
+}
+
+// checkTimeLimit returns ErrExpired when a time-limited GhostString
+// is older than its max age, allowing for the given clock skew.
+func checkTimeLimit(gs *GhostString, now time.Time, skew time.Duration) error {
+	if gs.MaxAge <= 0 {
+		return nil
+	}
+
+	if gs.IssuedAt.After(now.Add(skew)) {
+		return errors.Wrapf(Err, "issued in the future at %[1]v", gs.IssuedAt)
+	}
+
+	if now.Sub(gs.IssuedAt) > gs.MaxAge+skew {
+		return errors.Wrapf(ErrExpired, "issued at %[1]v with max age %[2]v", gs.IssuedAt, gs.MaxAge)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package ghoststring_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostString_TimeLimited(t *testing.T) {
+	issuedAt := time.UnixMilli(1661351759000)
+
+	for _, tc := range []struct {
+		name   string
+		now    time.Time
+		skew   time.Duration
+		maxAge time.Duration
+		err    error
+	}{
+		{
+			name: "no time limit",
+			now:  issuedAt.Add(24 * time.Hour),
+		},
+		{
+			name:   "fresh",
+			now:    issuedAt.Add(time.Minute),
+			maxAge: 5 * time.Minute,
+		},
+		{
+			name:   "expired",
+			now:    issuedAt.Add(6 * time.Minute),
+			maxAge: 5 * time.Minute,
+			err:    ghoststring.ErrExpired,
+		},
+		{
+			name:   "expired within skew",
+			now:    issuedAt.Add(6 * time.Minute),
+			skew:   2 * time.Minute,
+			maxAge: 5 * time.Minute,
+		},
+		{
+			name:   "issued in the future",
+			now:    issuedAt.Add(-time.Minute),
+			maxAge: 5 * time.Minute,
+			err:    ghoststring.Err,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+
+			gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+				"test.ttl",
+				"ticking clock crocodile",
+				ghoststring.WithClock(func() time.Time { return tc.now }),
+				ghoststring.WithClockSkew(tc.skew),
+			)
+			r.Nil(err)
+
+			s, err := gh.Ghostify(
+				&ghoststring.GhostString{
+					Namespace: "test.ttl",
+					Str:       "tick tock",
+					MaxAge:    tc.maxAge,
+					IssuedAt:  issuedAt,
+				},
+			)
+			r.Nil(err)
+			r.Contains(s, ghoststring.Prefix)
+
+			gs, err := gh.Unghostify(s)
+			if tc.err != nil {
+				r.ErrorIs(err, tc.err)
+				r.Nil(gs)
+				return
+			}
+
+			r.Nil(err)
+			r.Equal("tick tock", gs.Str)
+			r.Equal(tc.maxAge, gs.MaxAge)
+
+			if tc.maxAge != 0 {
+				r.True(issuedAt.Equal(gs.IssuedAt))
+			}
+		})
+	}
+}
+
+func TestGhostString_TimeLimitedTampering(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.ttl", "ticking clock crocodile")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	b, err := json.Marshal(
+		&ghoststring.GhostString{
+			Namespace: "test.ttl",
+			Str:       "tick tock",
+			MaxAge:    time.Hour,
+		},
+	)
+	r.Nil(err)
+
+	gs := &ghoststring.GhostString{}
+	r.Nil(json.Unmarshal(b, gs))
+	r.Equal(time.Hour, gs.MaxAge)
+
+	s := ""
+	r.Nil(json.Unmarshal(b, &s))
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, ghoststring.Prefix))
+	r.Nil(err)
+
+	longerHeader := base64.RawURLEncoding.EncodeToString(
+		[]byte(fmt.Sprintf(`{"iat":"%[1]d","ttl":"%[2]d"}`, time.Now().UnixMilli(), (24 * time.Hour).Milliseconds())),
+	)
+
+	headerStart := ghoststring.Nonce + bytes.Index(raw[ghoststring.Nonce:], []byte(ghoststring.HeaderSeparator))
+	headerEnd := ghoststring.Nonce + bytes.Index(raw[ghoststring.Nonce:], []byte(ghoststring.NamespaceSeparator))
+	r.Greater(headerEnd, headerStart)
+
+	tampered := append([]byte{}, raw[:headerStart+1]...)
+	tampered = append(tampered, []byte(longerHeader)...)
+	tampered = append(tampered, raw[headerEnd:]...)
+
+	_, err = gh.Unghostify(ghoststring.Prefix + base64.StdEncoding.EncodeToString(tampered))
+	r.NotNil(err)
+}
@@ -0,0 +1,149 @@
+package ghoststring
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+)
+
+var (
+	_ json.Marshaler             = &Ghost[any]{}
+	_ json.Unmarshaler           = &Ghost[any]{}
+	_ fmt.Stringer               = &Ghost[any]{}
+	_ fmt.GoStringer             = &Ghost[any]{}
+	_ encoding.TextMarshaler     = &Ghost[any]{}
+	_ encoding.TextUnmarshaler   = &Ghost[any]{}
+	_ encoding.BinaryMarshaler   = &Ghost[any]{}
+	_ encoding.BinaryUnmarshaler = &Ghost[any]{}
+)
+
+// Ghost wraps any JSON-serializable value with a JSON marshaller
+// that encodes the value as JSON and then ghostifies it as the
+// wrapped string value of a GhostString in the same namespace.
+type Ghost[T any] struct {
+	Namespace string
+	Value     T
+
+	gs GhostString
+}
+
+// IsValid checks that the namespace is valid
+func (g *Ghost[T]) IsValid() bool {
+	return validateNamespace(g.Namespace) == nil
+}
+
+func (g *Ghost[T]) String() string {
+	gs, err := g.toGhostString()
+	if err != nil {
+		return ""
+	}
+
+	return gs.String()
+}
+
+func (g *Ghost[T]) GoString() string {
+	return fmt.Sprintf(
+		"{%q, %q}",
+		g.Namespace,
+		g.String(),
+	)
+}
+
+// MarshalJSON allows Ghost to fulfill the json.Marshaler interface.
+func (g *Ghost[T]) MarshalJSON() ([]byte, error) {
+	gs, err := g.toGhostString()
+	if err != nil {
+		return nil, err
+	}
+
+	return gs.MarshalJSON()
+}
+
+// UnmarshalJSON allows Ghost to fulfill the json.Unmarshaler
+// interface. The unghostified string value is decoded as JSON into
+// the Value.
+func (g *Ghost[T]) UnmarshalJSON(b []byte) error {
+	if err := g.gs.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	return g.fromGhostString()
+}
+
+// MarshalText allows Ghost to fulfill the encoding.TextMarshaler
+// interface.
+func (g *Ghost[T]) MarshalText() ([]byte, error) {
+	gs, err := g.toGhostString()
+	if err != nil {
+		return nil, err
+	}
+
+	return gs.MarshalText()
+}
+
+// UnmarshalText allows Ghost to fulfill the
+// encoding.TextUnmarshaler interface.
+func (g *Ghost[T]) UnmarshalText(b []byte) error {
+	if err := g.gs.UnmarshalText(b); err != nil {
+		return err
+	}
+
+	return g.fromGhostString()
+}
+
+// MarshalBinary allows Ghost to fulfill the
+// encoding.BinaryMarshaler interface.
+func (g *Ghost[T]) MarshalBinary() ([]byte, error) {
+	gs, err := g.toGhostString()
+	if err != nil {
+		return nil, err
+	}
+
+	return gs.MarshalBinary()
+}
+
+// UnmarshalBinary allows Ghost to fulfill the
+// encoding.BinaryUnmarshaler interface.
+func (g *Ghost[T]) UnmarshalBinary(b []byte) error {
+	if err := g.gs.UnmarshalBinary(b); err != nil {
+		return err
+	}
+
+	return g.fromGhostString()
+}
+
+// toGhostString returns a copy of the underlying GhostString with
+// the JSON encoding of the Value, retaining any state that allows
+// for reuse of the ghostified string.
+func (g *Ghost[T]) toGhostString() (*GhostString, error) {
+	gs := g.gs.clone()
+
+	if gs.IsNull() && g.Namespace == "" {
+		return gs, nil
+	}
+
+	b, err := json.Marshal(g.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	gs.Namespace = g.Namespace
+	gs.Str = string(b)
+
+	return gs, nil
+}
+
+func (g *Ghost[T]) fromGhostString() error {
+	var value T
+
+	if g.gs.Str != "" {
+		if err := json.Unmarshal([]byte(g.gs.Str), &value); err != nil {
+			return err
+		}
+	}
+
+	g.Namespace = g.gs.Namespace
+	g.Value = value
+
+	return nil
+}
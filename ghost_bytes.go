@@ -0,0 +1,163 @@
+package ghoststring
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// BinaryPrefix begins the compact binary form of a ghostified
+	// value produced by GhostBytes.MarshalBinary, which is followed
+	// by the raw {nonce}{namespace}{NamespaceSeparator}{opaque-value}
+	// rather than its base64 encoding.
+	BinaryPrefix = "👻\x00"
+)
+
+var (
+	_ json.Marshaler             = &GhostBytes{}
+	_ json.Unmarshaler           = &GhostBytes{}
+	_ fmt.Stringer               = &GhostBytes{}
+	_ fmt.GoStringer             = &GhostBytes{}
+	_ encoding.TextMarshaler     = &GhostBytes{}
+	_ encoding.TextUnmarshaler   = &GhostBytes{}
+	_ encoding.BinaryMarshaler   = &GhostBytes{}
+	_ encoding.BinaryUnmarshaler = &GhostBytes{}
+)
+
+// GhostBytes wraps a byte slice in the same way as GhostString,
+// for binary secrets such as keys and certificates. The bytes are
+// encrypted as-is, without any intermediate encoding.
+type GhostBytes struct {
+	Namespace string
+	Bytes     []byte
+
+	gs GhostString
+}
+
+// IsValid checks that the wrapped bytes are non-empty and the
+// namespace is valid
+func (gb *GhostBytes) IsValid() bool {
+	return len(gb.Bytes) > 0 && validateNamespace(gb.Namespace) == nil
+}
+
+// Equal compares this GhostBytes to another
+func (gb *GhostBytes) Equal(other *GhostBytes) bool {
+	return other != nil &&
+		bytes.Equal(gb.Bytes, other.Bytes) &&
+		gb.Namespace == other.Namespace
+}
+
+func (gb *GhostBytes) String() string {
+	return gb.toGhostString().String()
+}
+
+func (gb *GhostBytes) GoString() string {
+	return fmt.Sprintf(
+		"{%q, %q}",
+		gb.Namespace,
+		gb.String(),
+	)
+}
+
+// MarshalJSON allows GhostBytes to fulfill the json.Marshaler
+// interface.
+func (gb *GhostBytes) MarshalJSON() ([]byte, error) {
+	return gb.toGhostString().MarshalJSON()
+}
+
+// UnmarshalJSON allows GhostBytes to fulfill the json.Unmarshaler
+// interface.
+func (gb *GhostBytes) UnmarshalJSON(b []byte) error {
+	if err := gb.gs.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	gb.fromGhostString()
+
+	return nil
+}
+
+// MarshalText allows GhostBytes to fulfill the
+// encoding.TextMarshaler interface.
+func (gb *GhostBytes) MarshalText() ([]byte, error) {
+	return gb.toGhostString().MarshalText()
+}
+
+// UnmarshalText allows GhostBytes to fulfill the
+// encoding.TextUnmarshaler interface.
+func (gb *GhostBytes) UnmarshalText(b []byte) error {
+	if err := gb.gs.UnmarshalText(b); err != nil {
+		return err
+	}
+
+	gb.fromGhostString()
+
+	return nil
+}
+
+// MarshalBinary allows GhostBytes to fulfill the
+// encoding.BinaryMarshaler interface. Unlike the text form, the
+// result is the compact binary form beginning with BinaryPrefix.
+func (gb *GhostBytes) MarshalBinary() ([]byte, error) {
+	s, err := gb.toGhostString().toString()
+	if err != nil {
+		return nil, err
+	}
+
+	if s == "" {
+		return []byte{}, nil
+	}
+
+	raw, err := decodeGhostified(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(BinaryPrefix), raw...), nil
+}
+
+// UnmarshalBinary allows GhostBytes to fulfill the
+// encoding.BinaryUnmarshaler interface. Both the compact binary
+// form and the text form are accepted.
+func (gb *GhostBytes) UnmarshalBinary(b []byte) error {
+	if !bytes.HasPrefix(b, []byte(BinaryPrefix)) {
+		return gb.UnmarshalText(b)
+	}
+
+	raw := b[len(BinaryPrefix):]
+	if len(raw) == 0 {
+		return errors.Wrap(Err, "empty binary value")
+	}
+
+	if err := gb.gs.fromString(encodeGhostified(raw)); err != nil {
+		return err
+	}
+
+	gb.fromGhostString()
+
+	return nil
+}
+
+// toGhostString returns a copy of the underlying GhostString with
+// the wrapped bytes, retaining any state that allows for reuse of
+// the ghostified string.
+func (gb *GhostBytes) toGhostString() *GhostString {
+	gs := gb.gs.clone()
+	gs.Namespace = gb.Namespace
+	gs.Str = string(gb.Bytes)
+
+	return gs
+}
+
+func (gb *GhostBytes) fromGhostString() {
+	gb.Namespace = gb.gs.Namespace
+	gb.Bytes = nil
+
+	if gb.gs.Str != "" {
+		gb.Bytes = []byte(gb.gs.Str)
+	}
+}
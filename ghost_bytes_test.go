@@ -0,0 +1,88 @@
+package ghoststring_test
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostBytes(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.bytes", "bits and pieces")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	secret := make([]byte, 256)
+	_, err = rand.Read(secret)
+	r.Nil(err)
+
+	gb := &ghoststring.GhostBytes{Namespace: "test.bytes", Bytes: secret}
+	r.True(gb.IsValid())
+
+	t.Run("json", func(t *testing.T) {
+		r := require.New(t)
+
+		b, err := json.Marshal(gb)
+		r.Nil(err)
+		r.NotContains(string(b), base64.StdEncoding.EncodeToString(secret))
+
+		fromJSON := &ghoststring.GhostBytes{}
+		r.Nil(json.Unmarshal(b, fromJSON))
+		r.True(gb.Equal(fromJSON))
+	})
+
+	t.Run("text", func(t *testing.T) {
+		r := require.New(t)
+
+		text, err := gb.MarshalText()
+		r.Nil(err)
+		r.Contains(string(text), ghoststring.Prefix)
+
+		fromText := &ghoststring.GhostBytes{}
+		r.Nil(fromText.UnmarshalText(text))
+		r.True(gb.Equal(fromText))
+
+		fromBinary := &ghoststring.GhostBytes{}
+		r.Nil(fromBinary.UnmarshalBinary(text))
+		r.True(gb.Equal(fromBinary))
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		r := require.New(t)
+
+		text, err := gb.MarshalText()
+		r.Nil(err)
+
+		bin, err := gb.MarshalBinary()
+		r.Nil(err)
+		r.True(len(bin) < len(text))
+		r.Equal(ghoststring.BinaryPrefix, string(bin[:len(ghoststring.BinaryPrefix)]))
+
+		fromBinary := &ghoststring.GhostBytes{}
+		r.Nil(fromBinary.UnmarshalBinary(bin))
+		r.True(gb.Equal(fromBinary))
+
+		r.NotNil((&ghoststring.GhostBytes{}).UnmarshalBinary([]byte(ghoststring.BinaryPrefix)))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		r := require.New(t)
+
+		empty := &ghoststring.GhostBytes{Namespace: "test.bytes"}
+		r.False(empty.IsValid())
+
+		bin, err := empty.MarshalBinary()
+		r.Nil(err)
+		r.Len(bin, 0)
+
+		fromBinary := &ghoststring.GhostBytes{Namespace: "test.bytes", Bytes: []byte("stale")}
+		r.Nil(fromBinary.UnmarshalBinary(bin))
+		r.Nil(fromBinary.Bytes)
+		r.Equal("", fromBinary.Namespace)
+	})
+}
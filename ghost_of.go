@@ -0,0 +1,137 @@
+package ghoststring
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	_ json.Marshaler             = &GhostOf[Namespacer]{}
+	_ json.Unmarshaler           = &GhostOf[Namespacer]{}
+	_ fmt.Stringer               = &GhostOf[Namespacer]{}
+	_ fmt.GoStringer             = &GhostOf[Namespacer]{}
+	_ encoding.TextMarshaler     = &GhostOf[Namespacer]{}
+	_ encoding.TextUnmarshaler   = &GhostOf[Namespacer]{}
+	_ encoding.BinaryMarshaler   = &GhostOf[Namespacer]{}
+	_ encoding.BinaryUnmarshaler = &GhostOf[Namespacer]{}
+)
+
+// Namespacer provides a namespace, typically as a constant from a
+// method on an empty struct type, e.g.:
+//
+//	type HeckNamespace struct{}
+//
+//	func (HeckNamespace) Namespace() string { return "heck.example.org" }
+type Namespacer interface {
+	Namespace() string
+}
+
+// GhostOf wraps a string in the same way as GhostString, except
+// that the namespace is provided by the zero value of the type
+// parameter and so can never be accidentally left empty, e.g.:
+//
+//	type Message struct {
+//		Content ghoststring.GhostOf[HeckNamespace] `json:"content"`
+//	}
+type GhostOf[NS Namespacer] struct {
+	Str string
+
+	gs GhostString
+}
+
+// Namespace returns the namespace provided by the type parameter.
+func (g *GhostOf[NS]) Namespace() string {
+	var ns NS
+
+	return ns.Namespace()
+}
+
+// GhostString returns the equivalent GhostString.
+func (g *GhostOf[NS]) GhostString() *GhostString {
+	return &GhostString{Namespace: g.Namespace(), Str: g.Str}
+}
+
+// IsZero checks that the wrapped string value is empty.
+func (g *GhostOf[NS]) IsZero() bool {
+	return g.Str == ""
+}
+
+func (g *GhostOf[NS]) String() string {
+	return g.toGhostString().String()
+}
+
+func (g *GhostOf[NS]) GoString() string {
+	return g.toGhostString().GoString()
+}
+
+// MarshalJSON allows GhostOf to fulfill the json.Marshaler
+// interface.
+func (g *GhostOf[NS]) MarshalJSON() ([]byte, error) {
+	return g.toGhostString().MarshalJSON()
+}
+
+// UnmarshalJSON allows GhostOf to fulfill the json.Unmarshaler
+// interface. A value in any namespace other than the one provided
+// by the type parameter is considered an error.
+func (g *GhostOf[NS]) UnmarshalJSON(b []byte) error {
+	return g.from(g.gs.UnmarshalJSON(b))
+}
+
+// MarshalText allows GhostOf to fulfill the encoding.TextMarshaler
+// interface.
+func (g *GhostOf[NS]) MarshalText() ([]byte, error) {
+	return g.toGhostString().MarshalText()
+}
+
+// UnmarshalText allows GhostOf to fulfill the
+// encoding.TextUnmarshaler interface.
+func (g *GhostOf[NS]) UnmarshalText(b []byte) error {
+	return g.from(g.gs.UnmarshalText(b))
+}
+
+// MarshalBinary allows GhostOf to fulfill the
+// encoding.BinaryMarshaler interface.
+func (g *GhostOf[NS]) MarshalBinary() ([]byte, error) {
+	return g.toGhostString().MarshalBinary()
+}
+
+// UnmarshalBinary allows GhostOf to fulfill the
+// encoding.BinaryUnmarshaler interface.
+func (g *GhostOf[NS]) UnmarshalBinary(b []byte) error {
+	return g.from(g.gs.UnmarshalBinary(b))
+}
+
+// toGhostString returns a copy of the underlying GhostString with
+// the wrapped string value and type parameter namespace, retaining
+// any state that allows for reuse of the ghostified string.
+func (g *GhostOf[NS]) toGhostString() *GhostString {
+	gs := g.gs.clone()
+
+	if !gs.IsNull() || g.Str != "" {
+		gs.Namespace = g.Namespace()
+		gs.Str = g.Str
+	}
+
+	return gs
+}
+
+func (g *GhostOf[NS]) from(err error) error {
+	if err != nil {
+		return err
+	}
+
+	if !g.gs.IsZero() && !g.gs.IsNull() && g.gs.Namespace != g.Namespace() {
+		namespace := g.gs.Namespace
+		g.gs = GhostString{}
+		g.Str = ""
+
+		return errors.Wrapf(Err, "unexpected namespace %[1]q instead of %[2]q", namespace, g.Namespace())
+	}
+
+	g.Str = g.gs.Str
+
+	return nil
+}
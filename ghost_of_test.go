@@ -0,0 +1,72 @@
+package ghoststring_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+type testHeckNamespace struct{}
+
+func (testHeckNamespace) Namespace() string { return "test.heck" }
+
+type testWatNamespace struct{}
+
+func (testWatNamespace) Namespace() string { return "test.wat" }
+
+func TestGhostOf(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.heck", "bring donuts")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	watGh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.wat", "zzz")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(watGh))
+
+	type message struct {
+		Recipient string                                   `json:"recipient"`
+		Content   ghoststring.GhostOf[testHeckNamespace]   `json:"content"`
+		Mood      ghoststring.GhostOf[testHeckNamespace]   `json:"mood"`
+		Pointer   *ghoststring.GhostOf[testHeckNamespace]  `json:"pointer,omitempty"`
+		Slice     []ghoststring.GhostOf[testHeckNamespace] `json:"slice"`
+		Elsewhere ghoststring.GhostOf[testWatNamespace]    `json:"elsewhere"`
+	}
+
+	msg := &message{
+		Recipient: "morningstar@heck.example.org",
+		Content:   ghoststring.GhostOf[testHeckNamespace]{Str: "meet me at the fjord"},
+		Slice:     []ghoststring.GhostOf[testHeckNamespace]{{Str: "dawn"}},
+		Elsewhere: ghoststring.GhostOf[testWatNamespace]{Str: "zzz"},
+	}
+
+	r.Equal("test.heck", msg.Content.Namespace())
+	r.Equal("test.heck", msg.Content.GhostString().Namespace)
+
+	b, err := json.Marshal(msg)
+	r.Nil(err)
+	r.NotContains(string(b), "fjord")
+	r.Contains(string(b), `"mood":""`)
+
+	fromJSON := &message{}
+	r.Nil(json.Unmarshal(b, fromJSON))
+
+	r.Equal("meet me at the fjord", fromJSON.Content.Str)
+	r.True(fromJSON.Mood.IsZero())
+	r.Equal("dawn", fromJSON.Slice[0].Str)
+	r.Equal("zzz", fromJSON.Elsewhere.Str)
+
+	again, err := json.Marshal(fromJSON)
+	r.Nil(err)
+	r.Equal(string(b), string(again))
+
+	raw := map[string]any{}
+	r.Nil(json.Unmarshal(b, &raw))
+
+	wrongNamespace := &ghoststring.GhostOf[testHeckNamespace]{}
+	r.ErrorIs(wrongNamespace.UnmarshalText([]byte(raw["elsewhere"].(string))), ghoststring.Err)
+	r.Equal("", wrongNamespace.Str)
+}
@@ -0,0 +1,52 @@
+package ghoststring
+
+import (
+	"time"
+)
+
+// GhostStringFields is the JSON form of the fields of a
+// GhostString, as used in the protocols spoken with agents, plugins,
+// and the sidecar server.
+type GhostStringFields struct {
+	Namespace  string `json:"namespace"`
+	Str        string `json:"str"`
+	MaxAgeMS   int64  `json:"max_age_ms,omitempty"`
+	IssuedAtMS int64  `json:"issued_at_ms,omitempty"`
+	TokenID    string `json:"token_id,omitempty"`
+	Header     Header `json:"header,omitempty"`
+}
+
+// NewGhostStringFields returns the fields of the GhostString along
+// with its header.
+func NewGhostStringFields(gs *GhostString, header Header) GhostStringFields {
+	fields := GhostStringFields{
+		Namespace: gs.Namespace,
+		Str:       gs.Str,
+		MaxAgeMS:  gs.MaxAge.Milliseconds(),
+		TokenID:   gs.TokenID,
+		Header:    header,
+	}
+
+	if !gs.IssuedAt.IsZero() {
+		fields.IssuedAtMS = gs.IssuedAt.UnixMilli()
+	}
+
+	return fields
+}
+
+// GhostString returns the GhostString with the fields. The header
+// is not part of the GhostString and is read from the Header field.
+func (fields *GhostStringFields) GhostString() *GhostString {
+	gs := &GhostString{
+		Namespace: fields.Namespace,
+		Str:       fields.Str,
+		MaxAge:    time.Duration(fields.MaxAgeMS) * time.Millisecond,
+		TokenID:   fields.TokenID,
+	}
+
+	if fields.IssuedAtMS != 0 {
+		gs.IssuedAt = time.UnixMilli(fields.IssuedAtMS)
+	}
+
+	return gs
+}
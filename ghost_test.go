@@ -0,0 +1,70 @@
+package ghoststring_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhost(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.ghost", "structured secrets")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	type credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	type account struct {
+		Name        string                               `json:"name"`
+		Credentials ghoststring.Ghost[credentials]       `json:"credentials"`
+		IDs         ghoststring.Ghost[[]int64]           `json:"ids"`
+		Unset       ghoststring.Ghost[map[string]string] `json:"unset"`
+	}
+
+	acct := &account{
+		Name: "boo",
+		Credentials: ghoststring.Ghost[credentials]{
+			Namespace: "test.ghost",
+			Value:     credentials{Username: "boo", Password: "radley!"},
+		},
+		IDs: ghoststring.Ghost[[]int64]{
+			Namespace: "test.ghost",
+			Value:     []int64{4, 8, 15, 16, 23, 42},
+		},
+	}
+
+	b, err := json.Marshal(acct)
+	r.Nil(err)
+	r.NotContains(string(b), "radley!")
+	r.NotContains(string(b), "16,23")
+	r.Contains(string(b), `"unset":""`)
+
+	fromJSON := &account{}
+	r.Nil(json.Unmarshal(b, fromJSON))
+
+	r.Equal("test.ghost", fromJSON.Credentials.Namespace)
+	r.Equal(acct.Credentials.Value, fromJSON.Credentials.Value)
+	r.Equal(acct.IDs.Value, fromJSON.IDs.Value)
+	r.Nil(fromJSON.Unset.Value)
+
+	again, err := json.Marshal(fromJSON)
+	r.Nil(err)
+	r.Equal(string(b), string(again))
+
+	text, err := acct.Credentials.MarshalText()
+	r.Nil(err)
+	r.Contains(string(text), ghoststring.Prefix)
+
+	fromText := &ghoststring.Ghost[credentials]{}
+	r.Nil(fromText.UnmarshalText(text))
+	r.Equal(acct.Credentials.Value, fromText.Value)
+
+	mismatched := &ghoststring.Ghost[int]{}
+	r.NotNil(mismatched.UnmarshalText(text))
+}
@@ -0,0 +1,250 @@
+package ghoststring
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"unsafe"
+)
+
+const (
+	keyIDLen = 8
+
+	ghostifiedCacheSize = 4096
+)
+
+var (
+	_ keyIdentifier = &aes256GcmSingleKeyGhostifyer{}
+	_ keyIdentifier = &aes256GcmMultiKeyGhostifyer{}
+)
+
+// keyIdentifier is implemented by Ghostifyers that are able to
+// identify the key and options currently used for encryption, which
+// allows a GhostString to re-emit the ghostified string it was
+// decoded from or last produced for as long as both remain active.
+type keyIdentifier interface {
+	latestKeyID() (string, error)
+	options() *ghostifyerOptions
+}
+
+// ghostifiedValue is a ghostified string along with a digest of
+// the state of the GhostString and the key and options that it
+// corresponds to.
+type ghostifiedValue struct {
+	s     string
+	keyID string
+
+	// opts are the options that the string was produced with or,
+	// if it was decoded, conforms to. The string is only reused by
+	// a Ghostifyer with the same options.
+	opts *ghostifyerOptions
+
+	digest [sha256.Size]byte
+}
+
+// ghostifiedCache holds the ghostified string that each GhostString
+// was decoded from or last produced, keyed by the address of the
+// GhostString, so that marshaling does not modify the value and may
+// be done concurrently. Entries are only reused while the digest of
+// the GhostString matches, so a value that later occupies the same
+// address only reuses a string with the same contents. The cache is
+// bounded, after which arbitrary entries are evicted.
+type ghostifiedCache struct {
+	lock    sync.Mutex
+	key     []byte
+	entries map[uintptr]*ghostifiedValue
+}
+
+var ghostifieds = newGhostifiedCache()
+
+func newGhostifiedCache() *ghostifiedCache {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+
+	return &ghostifiedCache{key: key, entries: map[uintptr]*ghostifiedValue{}}
+}
+
+// digest returns a keyed digest of the state of the GhostString so
+// that the cache does not retain the wrapped string value. The
+// second return value is false for a time-limited GhostString
+// without an issue time, which is issued at the current time when
+// ghostified and so may not reuse an earlier string.
+func (c *ghostifiedCache) digest(gs *GhostString) ([sha256.Size]byte, bool) {
+	var sum [sha256.Size]byte
+
+	if gs.MaxAge > 0 && gs.IssuedAt.IsZero() {
+		return sum, false
+	}
+
+	mac := hmac.New(sha256.New, c.key)
+
+	for _, field := range []string{
+		gs.Namespace,
+		gs.Str,
+		strconv.FormatInt(int64(gs.MaxAge), 10),
+		strconv.FormatInt(gs.IssuedAt.UnixNano(), 10),
+		gs.TokenID,
+	} {
+		_, _ = mac.Write([]byte(strconv.Itoa(len(field)) + ":" + field))
+	}
+
+	copy(sum[:], mac.Sum(nil))
+
+	return sum, true
+}
+
+func (c *ghostifiedCache) load(gs *GhostString) *ghostifiedValue {
+	digest, ok := c.digest(gs)
+	if !ok {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	gv := c.entries[gs.cacheKey()]
+	if gv == nil || gv.digest != digest {
+		return nil
+	}
+
+	return gv
+}
+
+func (c *ghostifiedCache) store(gs *GhostString, s, keyID string, opts *ghostifyerOptions) {
+	digest, ok := c.digest(gs)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !ok || s == "" || keyID == "" || opts == nil {
+		delete(c.entries, gs.cacheKey())
+
+		return
+	}
+
+	if len(c.entries) >= ghostifiedCacheSize {
+		for evicted := range c.entries {
+			delete(c.entries, evicted)
+
+			break
+		}
+	}
+
+	c.entries[gs.cacheKey()] = &ghostifiedValue{s: s, keyID: keyID, opts: opts, digest: digest}
+}
+
+// conformingOptions returns the Ghostifyer's options if the decoded
+// ghostified string with the authenticated header is as they would
+// produce it, otherwise nil. Padded values never conform, since the
+// policy that padded them is not known.
+func conformingOptions(ghostifyer Ghostifyer, s string, header Header) *ghostifyerOptions {
+	ki, ok := ghostifyer.(keyIdentifier)
+	if !ok {
+		return nil
+	}
+
+	opts := ki.options()
+
+	if encodingOf(s) != opts.encoding {
+		return nil
+	}
+
+	if opts.padding != nil || header[HeaderPadding] != "" {
+		return nil
+	}
+
+	if opts.compression != (header[HeaderCompression] != "") {
+		return nil
+	}
+
+	for key, value := range opts.header {
+		if header[key] != value {
+			return nil
+		}
+	}
+
+	return opts
+}
+
+// reusableGhostified returns the ghostified string that the
+// GhostString was decoded from or last produced if it is still
+// valid for the Ghostifyer's active key and options, otherwise "".
+// The key ID and options are returned for use in remembering a
+// newly ghostified string.
+func (gs *GhostString) reusableGhostified(ghostifyer Ghostifyer) (string, string, *ghostifyerOptions) {
+	ki, ok := ghostifyer.(keyIdentifier)
+	if !ok {
+		return "", "", nil
+	}
+
+	keyID, err := ki.latestKeyID()
+	if err != nil || keyID == "" {
+		return "", "", nil
+	}
+
+	opts := ki.options()
+
+	if gv := ghostifieds.load(gs); gv != nil && gv.opts == opts && gv.keyID == keyID {
+		return gv.s, keyID, opts
+	}
+
+	return "", keyID, opts
+}
+
+// NeedsRewrap checks if the GhostString was unmarshaled from a
+// value ghostified with a key other than the one now used by the
+// Ghostifyer registered for its namespace, in which case marshaling
+// it again, such as when writing a record back, rewraps it with the
+// current key.
+func (gs *GhostString) NeedsRewrap() bool {
+	gv := ghostifieds.load(gs)
+	if gv == nil {
+		return false
+	}
+
+	ghostifyer, ok := getGhostifyer(gs.Namespace)
+	if !ok {
+		return false
+	}
+
+	_, keyID, _ := gs.reusableGhostified(ghostifyer)
+
+	return keyID != "" && keyID != gv.keyID
+}
+
+// clone returns a copy of the GhostString that shares its entry in
+// the ghostified cache, which allows wrapping types to marshal
+// without modifying the GhostString they embed.
+func (gs *GhostString) clone() *GhostString {
+	c := *gs
+	c.cacheOwner = gs.owner()
+
+	return &c
+}
+
+func (gs *GhostString) owner() *GhostString {
+	if gs.cacheOwner != nil {
+		return gs.cacheOwner
+	}
+
+	return gs
+}
+
+func (gs *GhostString) cacheKey() uintptr {
+	return uintptr(unsafe.Pointer(gs.owner()))
+}
+
+func (gs *GhostString) rememberGhostified(s, keyID string, opts *ghostifyerOptions) {
+	ghostifieds.store(gs, s, keyID, opts)
+}
+
+func aes256GcmKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+
+	return hex.EncodeToString(sum[:keyIDLen])
+}
@@ -0,0 +1,254 @@
+package ghoststring_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostString_StableRemarshal(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.stable", "same as it ever was")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	gs := &ghoststring.GhostString{Namespace: "test.stable", Str: "letting the days go by"}
+
+	first := gs.String()
+	r.Contains(first, ghoststring.Prefix)
+	r.Equal(first, gs.String())
+
+	b, err := json.Marshal(gs)
+	r.Nil(err)
+	r.Equal(fmt.Sprintf("%q", first), string(b))
+
+	fromJSON := &ghoststring.GhostString{}
+	r.Nil(json.Unmarshal(b, fromJSON))
+
+	again, err := json.Marshal(fromJSON)
+	r.Nil(err)
+	r.Equal(string(b), string(again))
+
+	fromJSON.Str = "water flowing underground"
+	r.NotEqual(first, fromJSON.String())
+
+	fromJSON.Str = "letting the days go by"
+	r.NotEqual(first, fromJSON.String())
+
+	rotated, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.stable", "not the same as it ever was")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(rotated))
+
+	r.NotEqual(first, gs.String())
+}
+
+func TestGhostString_StableRemarshalMultiKey(t *testing.T) {
+	r := require.New(t)
+
+	ks, err := ghoststring.NewKeyStore(
+		"test.stable",
+		[]*ghoststring.TimestampedKey{
+			{Timestamp: 1661351759000, Key: "once in a lifetime"},
+			{Timestamp: 1661351742000, Key: "same as it ever was"},
+		},
+	)
+	r.Nil(err)
+
+	old, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.stable", "same as it ever was")
+	r.Nil(err)
+
+	oldStr, err := old.Ghostify(&ghoststring.GhostString{Namespace: "test.stable", Str: "into the blue again"})
+	r.Nil(err)
+
+	r.Nil(ghoststring.SetGhostifyer(ghoststring.NewAES256GCMMultiKeyGhostifyer("test.stable", ks)))
+
+	gs := &ghoststring.GhostString{}
+	r.Nil(gs.UnmarshalText([]byte(oldStr)))
+	r.Equal("into the blue again", gs.Str)
+	r.True(gs.NeedsRewrap())
+
+	latestStr := gs.String()
+	r.NotEqual(oldStr, latestStr)
+	r.Equal(latestStr, gs.String())
+	r.False(gs.NeedsRewrap())
+
+	fresh := &ghoststring.GhostString{}
+	r.Nil(fresh.UnmarshalText([]byte(latestStr)))
+	r.False(fresh.NeedsRewrap())
+
+	_, err = old.Unghostify(latestStr)
+	r.NotNil(err)
+}
+
+func TestGhostString_StableRemarshalOptions(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.stable.opts", "road to nowhere")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	gs := &ghoststring.GhostString{Namespace: "test.stable.opts", Str: strings.Repeat("?", 100)}
+
+	first := gs.String()
+	r.True(strings.HasPrefix(first, ghoststring.Prefix))
+
+	decoded := &ghoststring.GhostString{}
+	r.Nil(decoded.UnmarshalText([]byte(first)))
+	r.Equal(first, decoded.String())
+
+	urlSafe, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+		"test.stable.opts",
+		"road to nowhere",
+		ghoststring.WithEncoding(ghoststring.EncodingURL),
+	)
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(urlSafe))
+
+	for _, s := range []string{gs.String(), decoded.String()} {
+		r.NotEqual(first, s)
+		r.True(strings.HasPrefix(s, ghoststring.URLPrefix))
+		r.NotContains(s, "/")
+	}
+
+	headered, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+		"test.stable.opts",
+		"road to nowhere",
+		ghoststring.WithHeaders(ghoststring.Header{ghoststring.HeaderIssuer: "talking heads"}),
+	)
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(headered))
+
+	h, err := ghoststring.PeekHeader(gs.String())
+	r.Nil(err)
+	r.Equal("talking heads", h[ghoststring.HeaderIssuer])
+}
+
+func TestGhostString_StableRemarshalTimeLimited(t *testing.T) {
+	r := require.New(t)
+
+	now := time.UnixMilli(1661351759000)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+		"test.stable.limited",
+		"once in a lifetime",
+		ghoststring.WithClock(func() time.Time { return now }),
+	)
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	gs := &ghoststring.GhostString{Namespace: "test.stable.limited", Str: "same as it ever was", MaxAge: time.Minute}
+	before := *gs
+
+	first := gs.String()
+	r.Contains(first, ghoststring.Prefix)
+	r.True(before == *gs)
+
+	now = now.Add(time.Hour)
+
+	second := gs.String()
+	r.NotEqual(first, second)
+
+	_, err = gh.Unghostify(first)
+	r.ErrorIs(err, ghoststring.ErrExpired)
+
+	un, err := gh.Unghostify(second)
+	r.Nil(err)
+	r.True(now.Equal(un.IssuedAt))
+
+	gs.IssuedAt = now
+	r.Equal(gs.String(), gs.String())
+}
+
+func TestConcurrentMarshal(t *testing.T) {
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.stable.concurrent", "all at once")
+	require.Nil(t, err)
+	require.Nil(t, ghoststring.SetGhostifyer(gh))
+
+	heck, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.heck", "bring donuts")
+	require.Nil(t, err)
+	require.Nil(t, ghoststring.SetGhostifyer(heck))
+
+	gs := &ghoststring.GhostString{Namespace: "test.stable.concurrent", Str: "same as it ever was"}
+	g := &ghoststring.Ghost[[]string]{Namespace: "test.stable.concurrent", Value: []string{"all", "together", "now"}}
+	gOf := &ghoststring.GhostOf[testHeckNamespace]{Str: "all together now"}
+	gb := &ghoststring.GhostBytes{Namespace: "test.stable.concurrent", Bytes: []byte("all together now")}
+
+	for _, tc := range []struct {
+		name    string
+		marshal func() ([]byte, error)
+		check   func(*require.Assertions, []byte)
+	}{
+		{
+			name:    "GhostString",
+			marshal: func() ([]byte, error) { return json.Marshal(gs) },
+			check: func(r *require.Assertions, b []byte) {
+				fromJSON := &ghoststring.GhostString{}
+				r.Nil(json.Unmarshal(b, fromJSON))
+				r.True(gs.Equal(fromJSON))
+			},
+		},
+		{
+			name:    "Ghost",
+			marshal: func() ([]byte, error) { return json.Marshal(g) },
+			check: func(r *require.Assertions, b []byte) {
+				fromJSON := &ghoststring.Ghost[[]string]{}
+				r.Nil(json.Unmarshal(b, fromJSON))
+				r.Equal(g.Value, fromJSON.Value)
+			},
+		},
+		{
+			name:    "GhostOf",
+			marshal: func() ([]byte, error) { return json.Marshal(gOf) },
+			check: func(r *require.Assertions, b []byte) {
+				fromJSON := &ghoststring.GhostOf[testHeckNamespace]{}
+				r.Nil(json.Unmarshal(b, fromJSON))
+				r.Equal(gOf.Str, fromJSON.Str)
+			},
+		},
+		{
+			name:    "GhostBytes",
+			marshal: gb.MarshalBinary,
+			check: func(r *require.Assertions, b []byte) {
+				fromBinary := &ghoststring.GhostBytes{}
+				r.Nil(fromBinary.UnmarshalBinary(b))
+				r.Equal(gb.Bytes, fromBinary.Bytes)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+
+			start := make(chan struct{})
+			results := make([][]byte, 16)
+			errs := make([]error, len(results))
+			wg := &sync.WaitGroup{}
+
+			for i := range results {
+				wg.Add(1)
+
+				go func(i int) {
+					defer wg.Done()
+
+					<-start
+
+					results[i], errs[i] = tc.marshal()
+				}(i)
+			}
+
+			close(start)
+			wg.Wait()
+
+			for i, b := range results {
+				r.Nil(errs[i])
+				tc.check(r, b)
+			}
+		})
+	}
+}
@@ -31,3 +31,12 @@ func SetGhostifyer(gh Ghostifyer) error {
 
 	return nil
 }
+
+func getGhostifyer(namespace string) (Ghostifyer, bool) {
+	ghostifyersLock.RLock()
+	defer ghostifyersLock.RUnlock()
+
+	ghostifyer, ok := ghostifyers[namespace]
+
+	return ghostifyer, ok
+}
@@ -0,0 +1,152 @@
+package ghoststring
+
+import (
+	"time"
+)
+
+// GhostifyerOption configures optional behavior of the Ghostifyers
+// created via NewAES256GCMSingleKeyGhostifyer and
+// NewAES256GCMMultiKeyGhostifyer.
+type GhostifyerOption func(*ghostifyerOptions)
+
+type ghostifyerOptions struct {
+	now         func() time.Time
+	clockSkew   time.Duration
+	replayCache ReplayCache
+	header      Header
+
+	emptyStrings bool
+
+	compression         bool
+	compressionLevel    int
+	maxDecompressedSize int64
+
+	padding PaddingPolicy
+
+	encoding Encoding
+}
+
+// shouldGhostify checks that the GhostString is valid or, when
+// enabled via WithEmptyStrings, an intentionally empty secret.
+func (o *ghostifyerOptions) shouldGhostify(gs *GhostString) bool {
+	if gs == nil {
+		return false
+	}
+
+	if o.emptyStrings && gs.Str == "" {
+		return validateNamespace(gs.Namespace) == nil
+	}
+
+	return gs.IsValid()
+}
+
+func newGhostifyerOptions(opts []GhostifyerOption) *ghostifyerOptions {
+	o := &ghostifyerOptions{
+		now:                 time.Now,
+		maxDecompressedSize: DefaultMaxDecompressedSize,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithClock sets the function used to determine the current time
+// when issuing and checking time-limited values. The default is
+// time.Now.
+func WithClock(now func() time.Time) GhostifyerOption {
+	return func(o *ghostifyerOptions) {
+		if now != nil {
+			o.now = now
+		}
+	}
+}
+
+// WithClockSkew sets the tolerance allowed when checking the age of
+// time-limited values, which accounts for clocks that disagree
+// between the systems that ghostify and unghostify. The default is
+// zero.
+func WithClockSkew(skew time.Duration) GhostifyerOption {
+	return func(o *ghostifyerOptions) {
+		if skew >= 0 {
+			o.clockSkew = skew
+		}
+	}
+}
+
+// WithReplayCache sets the ReplayCache used to reject one-time
+// values, i.e. those with a TokenID, that have already been
+// unghostified. Values without a TokenID are not checked.
+func WithReplayCache(rc ReplayCache) GhostifyerOption {
+	return func(o *ghostifyerOptions) {
+		o.replayCache = rc
+	}
+}
+
+// WithHeaders sets headers, such as HeaderIssuer and
+// HeaderEnvironment, that are included with every ghostified value
+// in addition to any given via GhostifyWithHeader.
+func WithHeaders(header Header) GhostifyerOption {
+	return func(o *ghostifyerOptions) {
+		o.header = header
+	}
+}
+
+// WithEmptyStrings enables or disables ghostifying of GhostStrings
+// with a valid namespace and an empty wrapped string value, which
+// allows an intentionally empty secret to be distinguished from
+// one that was never set. By default, such values are ghostified
+// as "".
+func WithEmptyStrings(enabled bool) GhostifyerOption {
+	return func(o *ghostifyerOptions) {
+		o.emptyStrings = enabled
+	}
+}
+
+// WithCompression enables DEFLATE compression of values before
+// encryption at the given compress/flate level, which is flagged in
+// the header so that values are decompressed when unghostified.
+// Compression is only used when it reduces the size of a value.
+// Because compression causes the length of a ghostified value to
+// depend on its contents, which may leak information about secrets
+// that are mixed with attacker-controlled data, it is disabled by
+// default.
+func WithCompression(level int) GhostifyerOption {
+	return func(o *ghostifyerOptions) {
+		o.compression = true
+		o.compressionLevel = level
+	}
+}
+
+// WithMaxDecompressedSize sets the limit on the size of a
+// compressed value once decompressed, beyond which unghostifying
+// the value returns an error. The default is
+// DefaultMaxDecompressedSize.
+func WithMaxDecompressedSize(size int64) GhostifyerOption {
+	return func(o *ghostifyerOptions) {
+		if size > 0 {
+			o.maxDecompressedSize = size
+		}
+	}
+}
+
+// WithPadding sets the PaddingPolicy used to pad values before
+// encryption, after any compression, so that the length of a
+// ghostified value does not reveal the exact length of the secret.
+// Padding is flagged in the header so that it is removed when
+// values are unghostified. By default, values are not padded.
+func WithPadding(policy PaddingPolicy) GhostifyerOption {
+	return func(o *ghostifyerOptions) {
+		o.padding = policy
+	}
+}
+
+// WithEncoding sets the Encoding of ghostified values. The default
+// is EncodingStd.
+func WithEncoding(enc Encoding) GhostifyerOption {
+	return func(o *ghostifyerOptions) {
+		o.encoding = enc
+	}
+}
@@ -1,12 +1,14 @@
 package ghoststring
 
 import (
+	"bytes"
 	"encoding"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -14,17 +16,24 @@ import (
 const (
 	NamespaceMatchRegexp = "^[a-zA-Z][-\\._a-zA-Z0-9]{1,254}[a-zA-Z0-9]$"
 	NamespaceSeparator   = "::"
+	HeaderSeparator      = ";"
 	Prefix               = "👻:"
 
 	Nonce = 12
 
 	envKeySafeNamespaceRegExp = "[^A-Z0-9_]"
 
+	jsonNull = "null"
+
 	namespacePartsLength = 2
 )
 
 var (
-	Err = errors.New("ghoststring error")
+	Err           = errors.New("ghoststring error")
+	ErrExpired    = errors.WithMessage(Err, "expired")
+	ErrReplayed   = errors.WithMessage(Err, "replayed")
+	ErrAudience   = errors.WithMessage(Err, "audience mismatch")
+	ErrNotAllowed = errors.WithMessage(Err, "not allowed")
 
 	envKeySafeNamespaceMatch = regexp.MustCompile(envKeySafeNamespaceRegExp)
 
@@ -46,12 +55,33 @@ var (
 type GhostString struct {
 	Namespace string
 	Str       string
+
+	// MaxAge, when non-zero, limits how long after IssuedAt the
+	// ghostified value may be unghostified before ErrExpired is
+	// returned. Both values are authenticated as part of the
+	// ghostified value.
+	MaxAge time.Duration
+	// IssuedAt is the time at which a time-limited value was
+	// ghostified. When zero, the Ghostifyer's current time is used.
+	IssuedAt time.Time
+	// TokenID, when non-empty, is embedded as a unique identifier
+	// that Ghostifyers configured with a ReplayCache use to reject
+	// any attempt to unghostify the value more than once. See
+	// NewTokenID.
+	TokenID string
+
+	passthrough  *passthroughValue
+	cacheOwner   *GhostString
+	wasPlaintext bool
+	null         bool
 }
 
 type unghostifyParts struct {
-	nonce     []byte
-	namespace string
-	opaque    string
+	nonce          []byte
+	namespace      string
+	header         Header
+	additionalData []byte
+	opaque         []byte
 }
 
 // IsValid checks that the wrapped string value is non-empty and
@@ -60,11 +90,32 @@ func (gs *GhostString) IsValid() bool {
 	return gs.Str != "" && validateNamespace(gs.Namespace) == nil
 }
 
-// Equal compares this GhostString to another
+// IsZero checks that the GhostString has neither a namespace nor a
+// wrapped string value and was not unmarshaled from JSON null,
+// i.e. that it was never set. A GhostString with a namespace and an
+// empty wrapped string value is considered an intentionally empty
+// secret, which is ghostified by Ghostifyers configured via
+// WithEmptyStrings.
+func (gs *GhostString) IsZero() bool {
+	return gs.Namespace == "" && gs.Str == "" && !gs.null
+}
+
+// IsNull checks that the GhostString was unmarshaled from JSON null
+// and has not been set since, in which case it is marshaled back to
+// JSON null.
+func (gs *GhostString) IsNull() bool {
+	return gs.null && gs.Namespace == "" && gs.Str == ""
+}
+
+// Equal compares this GhostString to another, including the
+// MaxAge, IssuedAt, and TokenID.
 func (gs *GhostString) Equal(other *GhostString) bool {
 	return other != nil &&
 		gs.Str == other.Str &&
-		gs.Namespace == other.Namespace
+		gs.Namespace == other.Namespace &&
+		gs.MaxAge == other.MaxAge &&
+		gs.IssuedAt.Equal(other.IssuedAt) &&
+		gs.TokenID == other.TokenID
 }
 
 func (gs *GhostString) String() string {
@@ -85,25 +136,73 @@ func (gs *GhostString) GoString() string {
 }
 
 func (gs *GhostString) toString() (string, error) {
-	ghostifyersLock.RLock()
-	ghostifyer, ok := ghostifyers[gs.Namespace]
-	ghostifyersLock.RUnlock()
+	if gs.IsPassthrough() {
+		return gs.passthrough.s, nil
+	}
 
+	ghostifyer, ok := getGhostifyer(gs.Namespace)
 	if !ok {
 		ghostifyer = internalNullGhostifyer
 	}
 
+	reusable, keyID, opts := gs.reusableGhostified(ghostifyer)
+	if reusable != "" {
+		return reusable, nil
+	}
+
 	s, err := ghostifyer.Ghostify(gs)
 	if err != nil {
 		return "", err
 	}
 
+	gs.rememberGhostified(s, keyID, opts)
+
 	return s, nil
 }
 
+// fromString replaces the GhostString with the result of
+// unghostifying the string, or the zero value if the string is
+// empty.
+func (gs *GhostString) fromString(s string) error {
+	if s == "" {
+		*gs = GhostString{}
+
+		return nil
+	}
+
+	if lp := toLegacyPlaintext(s); lp != nil {
+		*gs = *lp
+
+		return nil
+	}
+
+	un, err := metaUnghostify(s)
+	if err != nil {
+		if pt := toPassthrough(s); pt != nil {
+			*gs = *pt
+
+			return nil
+		}
+
+		return err
+	}
+
+	*gs = *un.GhostString
+
+	if ghostifyer, ok := getGhostifyer(gs.Namespace); ok {
+		gs.rememberGhostified(s, un.KeyID, conformingOptions(ghostifyer, s, un.Header))
+	}
+
+	return nil
+}
+
 // MarshalJSON allows GhostString to fulfill the json.Marshaler
 // interface. The lack of a namespace is considered an error.
 func (gs *GhostString) MarshalJSON() ([]byte, error) {
+	if gs.IsNull() {
+		return []byte(jsonNull), nil
+	}
+
 	s, err := gs.toString()
 	if err != nil {
 		return nil, err
@@ -113,35 +212,31 @@ func (gs *GhostString) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON allows GhostString to fulfill the json.Unmarshaler
-// interface. The bytes are first unmarshaled as a string and then
+// interface. JSON null is preserved as described by IsNull.
+// Otherwise the bytes are first unmarshaled as a string and then
 // if non-empty are passed through an "unghostify" step. The
 // expected structure of a marshalled GhostString is:
 //
 //	  "{Prefix}base64({nonce}{namespace}{NamespaceSeparator}{opaque-value})"
 //
-// where {nonce} has the length specified as Nonce.
+// where {nonce} has the length specified as Nonce. Values that carry
+// authenticated headers, such as time-limited values, extend the
+// namespace as:
+//
+//	  "{namespace}{HeaderSeparator}base64url(json({header}))"
 func (gs *GhostString) UnmarshalJSON(b []byte) error {
-	s := ""
-	if err := json.Unmarshal(b, &s); err != nil {
-		return err
-	}
-
-	if s == "" {
-		gs.Str = ""
-		gs.Namespace = ""
+	if string(bytes.TrimSpace(b)) == jsonNull {
+		*gs = GhostString{null: true}
 
 		return nil
 	}
 
-	un, err := metaUnghostify(s)
-	if err != nil {
+	s := ""
+	if err := json.Unmarshal(b, &s); err != nil {
 		return err
 	}
 
-	gs.Str = un.Str
-	gs.Namespace = un.Namespace
-
-	return nil
+	return gs.fromString(s)
 }
 
 // MarshalText allows GhostString to fulfill the encoding.TextMarshaler interface
@@ -156,22 +251,7 @@ func (gs *GhostString) MarshalText() ([]byte, error) {
 
 // UnmarshalText allows GhostString to fulfill the encoding.TextUnmarshaler interface
 func (gs *GhostString) UnmarshalText(b []byte) error {
-	if len(b) == 0 {
-		gs.Str = ""
-		gs.Namespace = ""
-
-		return nil
-	}
-
-	un, err := metaUnghostify(string(b))
-	if err != nil {
-		return err
-	}
-
-	gs.Str = un.Str
-	gs.Namespace = un.Namespace
-
-	return nil
+	return gs.fromString(string(b))
 }
 
 // MarshalBinary allows GhostString to fulfill the encoding.BinaryMarshaler interface
@@ -184,41 +264,104 @@ func (gs *GhostString) UnmarshalBinary(b []byte) error {
 	return gs.UnmarshalText(b)
 }
 
+// decodeGhostified returns the raw bytes of a ghostified string,
+// i.e. {nonce}{namespace}{NamespaceSeparator}{opaque-value}, in
+// any Encoding
+func decodeGhostified(s string) ([]byte, error) {
+	return decodeAnyEncoding(s)
+}
+
+// encodeGhostified returns the ghostified string form of raw bytes
+// as returned by decodeGhostified in the default Encoding.
+func encodeGhostified(raw []byte) string {
+	return EncodingStd.encode(raw)
+}
+
 func toUnghostifyParts(s string) (*unghostifyParts, error) {
-	nonceNsValueBytes, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, Prefix))
+	nonceNsValueBytes, err := decodeGhostified(s)
 	if err != nil {
 		return nil, err
 	}
 
+	if len(nonceNsValueBytes) < Nonce {
+		return nil, errors.Wrap(Err, "invalid nonce")
+	}
+
 	nonce, nsValueBytes := nonceNsValueBytes[:Nonce], nonceNsValueBytes[Nonce:]
 
-	nsParts := strings.SplitN(string(nsValueBytes), NamespaceSeparator, namespacePartsLength)
+	nsParts := bytes.SplitN(nsValueBytes, []byte(NamespaceSeparator), namespacePartsLength)
 	if len(nsParts) != namespacePartsLength {
 		return nil, errors.Wrap(Err, "invalid namespacing")
 	}
 
-	return &unghostifyParts{
+	unParts := &unghostifyParts{
 		nonce:     nonce,
-		namespace: nsParts[0],
+		namespace: string(nsParts[0]),
 		opaque:    nsParts[1],
-	}, nil
+	}
+
+	namespace, rawHeader, hasHeader := strings.Cut(unParts.namespace, HeaderSeparator)
+	if !hasHeader {
+		return unParts, nil
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(rawHeader)
+	if err != nil {
+		return nil, errors.Wrap(Err, "invalid header encoding")
+	}
+
+	header := Header{}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, errors.Wrap(Err, "invalid header")
+	}
+
+	unParts.namespace = namespace
+	unParts.header = header
+	unParts.additionalData = nsParts[0]
+
+	return unParts, nil
 }
 
-func metaUnghostify(s string) (*GhostString, error) {
+// toNamespaceLabel returns the namespace extended with the encoded
+// header, if any, which is also used as the additional
+// authenticated data when encrypting.
+func toNamespaceLabel(namespace string, header Header) (string, error) {
+	if len(header) == 0 {
+		return namespace, nil
+	}
+
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	return namespace + HeaderSeparator + base64.RawURLEncoding.EncodeToString(headerBytes), nil
+}
+
+func toGhostified(nonce []byte, namespaceLabel string, encBytes []byte, enc Encoding) string {
+	return enc.encode(
+		append(
+			append(
+				nonce,
+				[]byte(namespaceLabel+NamespaceSeparator)...,
+			),
+			encBytes...,
+		),
+	)
+}
+
+func metaUnghostify(s string) (*Unghostified, error) {
 	unParts, err := toUnghostifyParts(s)
 	if err != nil {
 		return nil, err
 	}
 
-	ghostifyersLock.RLock()
-	ghostifyer, ok := ghostifyers[unParts.namespace]
-	ghostifyersLock.RUnlock()
-
+	ghostifyer, ok := getGhostifyer(unParts.namespace)
 	if !ok {
 		return nil, errors.Wrapf(Err, "no ghostifyer set for namespace %[1]q", unParts.namespace)
 	}
 
-	return ghostifyer.Unghostify(s)
+	return unghostifyDetailed(ghostifyer, s)
 }
 
 func validateNamespace(namespace string) error {
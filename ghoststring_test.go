@@ -223,6 +223,74 @@ func TestGhostString_BinaryMarshalRoundTrip(t *testing.T) {
 	}
 }
 
+func TestGhostString_EmptyAndNull(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+		"test.empty",
+		"nothing to see here",
+		ghoststring.WithEmptyStrings(true),
+	)
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	type profile struct {
+		Cleared ghoststring.GhostString  `json:"cleared"`
+		Unset   ghoststring.GhostString  `json:"unset"`
+		Null    ghoststring.GhostString  `json:"null"`
+		Pointer *ghoststring.GhostString `json:"pointer"`
+	}
+
+	b, err := json.Marshal(
+		&profile{
+			Cleared: ghoststring.GhostString{Namespace: "test.empty"},
+		},
+	)
+	r.Nil(err)
+
+	raw := map[string]any{}
+	r.Nil(json.Unmarshal(b, &raw))
+	r.Contains(raw["cleared"], ghoststring.Prefix)
+	r.Equal("", raw["unset"])
+	r.Equal("", raw["null"])
+	r.Nil(raw["pointer"])
+
+	p := &profile{}
+	r.Nil(json.Unmarshal([]byte(`{"cleared":`+fmt.Sprintf("%q", raw["cleared"])+`,"unset":"","null":null}`), p))
+
+	r.Equal("test.empty", p.Cleared.Namespace)
+	r.Equal("", p.Cleared.Str)
+	r.False(p.Cleared.IsZero())
+	r.False(p.Cleared.IsNull())
+
+	r.True(p.Unset.IsZero())
+	r.False(p.Unset.IsNull())
+
+	r.False(p.Null.IsZero())
+	r.True(p.Null.IsNull())
+
+	again, err := json.Marshal(p)
+	r.Nil(err)
+
+	raw = map[string]any{}
+	r.Nil(json.Unmarshal(again, &raw))
+	r.Contains(raw["cleared"], ghoststring.Prefix)
+	r.Equal("", raw["unset"])
+	r.Nil(raw["null"])
+	r.Contains(raw, "null")
+
+	p.Null.Namespace = "test.empty"
+	p.Null.Str = "something after all"
+	r.False(p.Null.IsNull())
+
+	withoutEmpty, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.empty", "nothing to see here")
+	r.Nil(err)
+
+	s, err := withoutEmpty.Ghostify(&ghoststring.GhostString{Namespace: "test.empty"})
+	r.Nil(err)
+	r.Equal("", s)
+}
+
 func ExampleGhostString() {
 	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
 		"example",
@@ -0,0 +1,236 @@
+package ghoststring
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// HeaderIssuer is the conventional header for the service that
+	// ghostified a value.
+	HeaderIssuer = "iss"
+	// HeaderPurpose is the conventional header for the intended use
+	// of a ghostified value.
+	HeaderPurpose = "purpose"
+	// HeaderEnvironment is the conventional header for the
+	// environment in which a value was ghostified.
+	HeaderEnvironment = "env"
+	// HeaderAudience is the conventional header for the intended
+	// recipient of a ghostified value, as checked by OpenToken.
+	HeaderAudience = "aud"
+
+	// HeaderIssuedAt is reserved for the time in milliseconds since
+	// the Unix epoch at which a value with any header was
+	// ghostified.
+	HeaderIssuedAt = "iat"
+	// HeaderMaxAge is reserved for GhostString.MaxAge in
+	// milliseconds.
+	HeaderMaxAge = "ttl"
+	// HeaderTokenID is reserved for GhostString.TokenID.
+	HeaderTokenID = "jti"
+	// HeaderCompression is reserved for the compression, if any,
+	// applied to a value before encryption.
+	HeaderCompression = "z"
+	// HeaderPadding is reserved for the padding, if any, applied to
+	// a value before encryption.
+	HeaderPadding = "pad"
+)
+
+var (
+	reservedHeaders = map[string]bool{
+		HeaderIssuedAt:    true,
+		HeaderMaxAge:      true,
+		HeaderTokenID:     true,
+		HeaderCompression: true,
+		HeaderPadding:     true,
+	}
+
+	_ DetailedUnghostifyer = &aes256GcmSingleKeyGhostifyer{}
+	_ DetailedUnghostifyer = &aes256GcmMultiKeyGhostifyer{}
+	_ HeaderGhostifyer     = &aes256GcmSingleKeyGhostifyer{}
+	_ HeaderGhostifyer     = &aes256GcmMultiKeyGhostifyer{}
+	_ DetailedUnghostifyer = &agentGhostifyer{}
+	_ HeaderGhostifyer     = &agentGhostifyer{}
+	_ DetailedUnghostifyer = &pluginGhostifyer{}
+	_ HeaderGhostifyer     = &pluginGhostifyer{}
+)
+
+// Header is a set of non-secret metadata that is stored in the
+// clear alongside a ghostified value and authenticated when it is
+// unghostified.
+type Header map[string]string
+
+// Unghostified is the detailed result of unghostifying a value.
+type Unghostified struct {
+	GhostString *GhostString
+
+	// Header contains every authenticated header, including those
+	// reserved for use by Ghostifyers.
+	Header Header
+
+	// KeyID identifies the key that decrypted the value without
+	// revealing it, and is empty for Ghostifyers that do not
+	// identify their keys.
+	KeyID string
+	// KeyIndex is the index of the key that decrypted the value in
+	// KeyStore.All, or 0 for single-key Ghostifyers.
+	KeyIndex int
+	// KeyTimestamp is the timestamp of the key that decrypted the
+	// value if the KeyStore is a TimestampedKeyStore, otherwise
+	// zero.
+	KeyTimestamp time.Time
+	// Latest reports whether the key that decrypted the value is
+	// the one currently used for encryption. Values for which it
+	// is false may be rewrapped via Rewrap.
+	Latest bool
+}
+
+// UserHeader returns the subset of the Header that is not reserved
+// for use by Ghostifyers, or nil if there is none.
+func (un *Unghostified) UserHeader() Header {
+	return userHeader(un.Header)
+}
+
+// DetailedUnghostifyer is implemented by Ghostifyers that are able
+// to report the details of an unghostified value.
+type DetailedUnghostifyer interface {
+	UnghostifyDetailed(string) (*Unghostified, error)
+}
+
+// HeaderGhostifyer is implemented by Ghostifyers that are able to
+// include per-value headers, in addition to any set via
+// WithHeaders, in a ghostified value.
+type HeaderGhostifyer interface {
+	GhostifyWithHeader(*GhostString, Header) (string, error)
+}
+
+// UnghostifyDetailed unghostifies the string with the Ghostifyer
+// registered for its namespace, reporting the header along with
+// the GhostString.
+func UnghostifyDetailed(s string) (*Unghostified, error) {
+	return metaUnghostify(s)
+}
+
+// GhostifyWithHeader ghostifies the GhostString with the Ghostifyer
+// registered for its namespace, including the header, such as
+// HeaderPurpose, which is authenticated when the value is
+// unghostified and reported via UnghostifyDetailed.
+func GhostifyWithHeader(gs *GhostString, header Header) (string, error) {
+	ghostifyer, ok := getGhostifyer(gs.Namespace)
+	if !ok {
+		return "", errors.Wrapf(Err, "no ghostifyer set for namespace %[1]q", gs.Namespace)
+	}
+
+	return ghostifyWithHeader(ghostifyer, gs, header)
+}
+
+// ghostifyWithHeader ghostifies the GhostString with the header if
+// the Ghostifyer is a HeaderGhostifyer, and otherwise only if there
+// is no header to include.
+func ghostifyWithHeader(ghostifyer Ghostifyer, gs *GhostString, header Header) (string, error) {
+	if hg, ok := ghostifyer.(HeaderGhostifyer); ok {
+		return hg.GhostifyWithHeader(gs, header)
+	}
+
+	if len(header) > 0 {
+		return "", errors.Wrapf(Err, "ghostifyer for namespace %[1]q does not support headers", ghostifyer.Namespace())
+	}
+
+	return ghostifyer.Ghostify(gs)
+}
+
+// unghostifyDetailed unghostifies the string with the Ghostifyer,
+// reporting the header if it is a DetailedUnghostifyer and
+// otherwise the unauthenticated header read from the string.
+func unghostifyDetailed(ghostifyer Ghostifyer, s string) (*Unghostified, error) {
+	if dg, ok := ghostifyer.(DetailedUnghostifyer); ok {
+		return dg.UnghostifyDetailed(s)
+	}
+
+	gs, err := ghostifyer.Unghostify(s)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := PeekHeader(s)
+	if err != nil {
+		header = Header{}
+	}
+
+	return &Unghostified{GhostString: gs, Header: header}, nil
+}
+
+// PeekHeader reads the header of a ghostified value without
+// decrypting it. The header is NOT authenticated until the value is
+// unghostified, and so must not be trusted beyond informational
+// use.
+func PeekHeader(s string) (Header, error) {
+	unParts, err := toUnghostifyParts(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if unParts.header == nil {
+		return Header{}, nil
+	}
+
+	return unParts.header, nil
+}
+
+// PeekNamespace reads the namespace of a ghostified value without
+// decrypting it, such as to determine which Ghostifyer or policy
+// applies before unghostifying. Like PeekHeader, the namespace is
+// NOT authenticated until the value is unghostified.
+func PeekNamespace(s string) (string, error) {
+	unParts, err := toUnghostifyParts(s)
+	if err != nil {
+		return "", err
+	}
+
+	return unParts.namespace, nil
+}
+
+// newHeader combines the Ghostifyer-level and per-value headers
+// with those reserved for the GhostString's own fields.
+func newHeader(gs *GhostString, valueHeader Header, opts *ghostifyerOptions) (Header, error) {
+	header := Header{}
+
+	for _, h := range []Header{opts.header, valueHeader} {
+		for key, value := range h {
+			if reservedHeaders[key] {
+				return nil, errors.Wrapf(Err, "reserved header %[1]q", key)
+			}
+
+			header[key] = value
+		}
+	}
+
+	if gs.TokenID != "" {
+		header[HeaderTokenID] = gs.TokenID
+	}
+
+	setTimeLimitHeader(header, gs, opts.now())
+
+	return header, nil
+}
+
+// userHeader returns the subset of the header that is not reserved,
+// or nil if there is none.
+func userHeader(header Header) Header {
+	var uh Header
+
+	for key, value := range header {
+		if reservedHeaders[key] {
+			continue
+		}
+
+		if uh == nil {
+			uh = Header{}
+		}
+
+		uh[key] = value
+	}
+
+	return uh
+}
@@ -0,0 +1,129 @@
+package ghoststring_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostString_Header(t *testing.T) {
+	r := require.New(t)
+
+	now := time.UnixMilli(1661351759000)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+		"test.header",
+		"who goes there",
+		ghoststring.WithClock(func() time.Time { return now }),
+		ghoststring.WithHeaders(
+			ghoststring.Header{
+				ghoststring.HeaderIssuer:      "rectangles",
+				ghoststring.HeaderEnvironment: "test",
+			},
+		),
+	)
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	s, err := ghoststring.GhostifyWithHeader(
+		&ghoststring.GhostString{Namespace: "test.header", Str: "friend"},
+		ghoststring.Header{ghoststring.HeaderPurpose: "greeting"},
+	)
+	r.Nil(err)
+
+	peeked, err := ghoststring.PeekHeader(s)
+	r.Nil(err)
+	r.Equal(
+		ghoststring.Header{
+			ghoststring.HeaderIssuer:      "rectangles",
+			ghoststring.HeaderEnvironment: "test",
+			ghoststring.HeaderPurpose:     "greeting",
+			ghoststring.HeaderIssuedAt:    "1661351759000",
+		},
+		peeked,
+	)
+
+	un, err := ghoststring.UnghostifyDetailed(s)
+	r.Nil(err)
+	r.Equal(peeked, un.Header)
+	r.Equal("friend", un.GhostString.Str)
+	r.True(now.Equal(un.GhostString.IssuedAt))
+	r.Equal(
+		ghoststring.Header{
+			ghoststring.HeaderIssuer:      "rectangles",
+			ghoststring.HeaderEnvironment: "test",
+			ghoststring.HeaderPurpose:     "greeting",
+		},
+		un.UserHeader(),
+	)
+
+	b, err := json.Marshal(s)
+	r.Nil(err)
+
+	gs := &ghoststring.GhostString{}
+	r.Nil(json.Unmarshal(b, gs))
+	r.True(un.GhostString.Equal(gs))
+
+	_, err = ghoststring.GhostifyWithHeader(
+		&ghoststring.GhostString{Namespace: "test.header", Str: "foe"},
+		ghoststring.Header{ghoststring.HeaderTokenID: "sneaky"},
+	)
+	r.ErrorIs(err, ghoststring.Err)
+
+	plain, err := gh.Ghostify(&ghoststring.GhostString{Namespace: "test.header", Str: "stranger"})
+	r.Nil(err)
+
+	peeked, err = ghoststring.PeekHeader(plain)
+	r.Nil(err)
+	r.Equal("rectangles", peeked[ghoststring.HeaderIssuer])
+	r.NotContains(peeked, ghoststring.HeaderPurpose)
+
+	bare, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.header", "who goes there")
+	r.Nil(err)
+
+	plain, err = bare.Ghostify(&ghoststring.GhostString{Namespace: "test.header", Str: "stranger"})
+	r.Nil(err)
+
+	peeked, err = ghoststring.PeekHeader(plain)
+	r.Nil(err)
+	r.Len(peeked, 0)
+
+	namespace, err := ghoststring.PeekNamespace(s)
+	r.Nil(err)
+	r.Equal("test.header", namespace)
+
+	namespace, err = ghoststring.PeekNamespace(plain)
+	r.Nil(err)
+	r.Equal("test.header", namespace)
+}
+
+func TestGhostString_EqualMetadata(t *testing.T) {
+	r := require.New(t)
+
+	issuedAt := time.Now()
+
+	gs := &ghoststring.GhostString{
+		Namespace: "test.header",
+		Str:       "friend",
+		MaxAge:    time.Minute,
+		IssuedAt:  issuedAt,
+		TokenID:   "once",
+	}
+
+	same := *gs
+	same.IssuedAt = issuedAt.UTC()
+	r.True(gs.Equal(&same))
+
+	for _, modify := range []func(*ghoststring.GhostString){
+		func(other *ghoststring.GhostString) { other.MaxAge = time.Hour },
+		func(other *ghoststring.GhostString) { other.IssuedAt = issuedAt.Add(time.Second) },
+		func(other *ghoststring.GhostString) { other.TokenID = "twice" },
+	} {
+		other := same
+		modify(&other)
+		r.False(gs.Equal(&other))
+	}
+}
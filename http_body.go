@@ -0,0 +1,295 @@
+package ghoststring
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// BodyContentType is the content type of request and response
+	// bodies ghostified by GhostBodyHandler and GhostBodyTransport,
+	// which are in the stream form written by a GhostWriter.
+	BodyContentType = "application/vnd.ghoststring.stream"
+
+	// BodyContentTypeHeader is the HTTP header that carries the
+	// original content type of a ghostified body.
+	BodyContentTypeHeader = "Ghoststring-Content-Type"
+
+	// BodyAcceptHeader is the HTTP request header with which a
+	// client indicates the namespace in which it accepts ghostified
+	// response bodies.
+	BodyAcceptHeader = "Ghoststring-Accept"
+)
+
+var (
+	_ http.Handler      = &GhostBodyHandler{}
+	_ http.RoundTripper = &GhostBodyTransport{}
+)
+
+// GhostBodyHandler is http.Handler middleware that unghostifies
+// request bodies with the BodyContentType and ghostifies response
+// bodies for clients that send the BodyAcceptHeader, such as those
+// using a GhostBodyTransport, so that existing handlers may be
+// used unchanged.
+type GhostBodyHandler struct {
+	Namespace string
+	Handler   http.Handler
+
+	// Required rejects requests with bodies that are not ghostified
+	// and requests that do not accept ghostified responses.
+	Required bool
+}
+
+func (h *GhostBodyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") == BodyContentType {
+		if !h.hasKeys(w) {
+			return
+		}
+
+		gr, namespace, err := newGhostReader(r.Body)
+		if err != nil || namespace != h.Namespace {
+			http.Error(w, "invalid ghostified body", http.StatusBadRequest)
+			return
+		}
+
+		r = r.Clone(r.Context())
+		r.Body = &bodyReadCloser{Reader: gr, Closer: r.Body}
+		r.ContentLength = -1
+		r.Header.Del("Content-Length")
+		r.Header.Set("Content-Type", r.Header.Get(BodyContentTypeHeader))
+		r.Header.Del(BodyContentTypeHeader)
+
+		if r.Header.Get("Content-Type") == "" {
+			r.Header.Del("Content-Type")
+		}
+	} else if h.Required && r.ContentLength != 0 {
+		http.Error(w, "ghostified body required", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if r.Header.Get(BodyAcceptHeader) != h.Namespace || r.Method == http.MethodHead {
+		if h.Required && r.Method != http.MethodHead {
+			http.Error(w, "ghostified response required", http.StatusNotAcceptable)
+			return
+		}
+
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	if !h.hasKeys(w) {
+		return
+	}
+
+	bw := &ghostBodyResponseWriter{ResponseWriter: w, namespace: h.Namespace}
+	defer bw.close()
+
+	h.Handler.ServeHTTP(bw, r)
+}
+
+// hasKeys checks that the keys of the namespace are available before
+// ghostifying or unghostifying a body, so that a misconfiguration is
+// reported as a server error rather than as an invalid body or a
+// truncated response. Plain requests do not use the keys.
+func (h *GhostBodyHandler) hasKeys(w http.ResponseWriter) bool {
+	if _, _, err := getStreamKeys(h.Namespace); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
+// GhostBodyTransport is an http.RoundTripper that ghostifies
+// request bodies and unghostifies response bodies ghostified by a
+// GhostBodyHandler. Request bodies are ghostified in full before
+// sending so that requests may be retried.
+type GhostBodyTransport struct {
+	Namespace string
+
+	// Base is the http.RoundTripper used to send requests, which
+	// defaults to http.DefaultTransport.
+	Base http.RoundTripper
+
+	// Required returns an error for responses with bodies that are
+	// not ghostified.
+	Required bool
+}
+
+func (t *GhostBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ghostReq := req.Clone(req.Context())
+	ghostReq.Header.Set(BodyAcceptHeader, t.Namespace)
+
+	if req.Body != nil && req.Body != http.NoBody {
+		encBytes, err := t.ghostifyBody(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		if contentType := req.Header.Get("Content-Type"); contentType != "" {
+			ghostReq.Header.Set(BodyContentTypeHeader, contentType)
+		}
+
+		ghostReq.Header.Set("Content-Type", BodyContentType)
+		ghostReq.ContentLength = int64(len(encBytes))
+		ghostReq.Body = io.NopCloser(bytes.NewReader(encBytes))
+		ghostReq.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(encBytes)), nil
+		}
+	}
+
+	resp, err := base.RoundTrip(ghostReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Header.Get("Content-Type") != BodyContentType {
+		if t.Required && resp.ContentLength != 0 && req.Method != http.MethodHead {
+			_ = resp.Body.Close()
+
+			return nil, errors.Wrap(Err, "ghostified response required")
+		}
+
+		return resp, nil
+	}
+
+	gr, namespace, err := newGhostReader(resp.Body)
+	if err == nil && namespace != t.Namespace {
+		err = errors.Wrapf(Err, "response namespace %[1]q is not %[2]q", namespace, t.Namespace)
+	}
+
+	if err != nil {
+		_ = resp.Body.Close()
+
+		return nil, err
+	}
+
+	resp.Body = &bodyReadCloser{Reader: gr, Closer: resp.Body}
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	resp.Header.Set("Content-Type", resp.Header.Get(BodyContentTypeHeader))
+	resp.Header.Del(BodyContentTypeHeader)
+
+	if resp.Header.Get("Content-Type") == "" {
+		resp.Header.Del("Content-Type")
+	}
+
+	return resp, nil
+}
+
+func (t *GhostBodyTransport) ghostifyBody(body io.ReadCloser) ([]byte, error) {
+	defer func() { _ = body.Close() }()
+
+	buf := &bytes.Buffer{}
+
+	gw, err := NewGhostWriter(buf, t.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.Copy(gw, body); err != nil {
+		return nil, err
+	}
+
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type bodyReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// ghostBodyResponseWriter ghostifies the response body once the
+// response headers have been set.
+type ghostBodyResponseWriter struct {
+	http.ResponseWriter
+
+	namespace   string
+	gw          io.WriteCloser
+	wroteHeader bool
+	err         error
+}
+
+func (bw *ghostBodyResponseWriter) WriteHeader(code int) {
+	if bw.wroteHeader {
+		return
+	}
+
+	// Informational responses, such as 103 Early Hints, may precede
+	// the final response headers, which have yet to be written.
+	if code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols {
+		bw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	bw.wroteHeader = true
+
+	if !bodyAllowedForStatus(code) {
+		bw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	h := bw.Header()
+
+	if contentType := h.Get("Content-Type"); contentType != "" {
+		h.Set(BodyContentTypeHeader, contentType)
+	}
+
+	h.Set("Content-Type", BodyContentType)
+	h.Del("Content-Length")
+
+	bw.ResponseWriter.WriteHeader(code)
+
+	bw.gw, bw.err = NewGhostWriter(bw.ResponseWriter, bw.namespace)
+}
+
+func (bw *ghostBodyResponseWriter) Write(b []byte) (int, error) {
+	bw.WriteHeader(http.StatusOK)
+
+	if bw.err != nil {
+		return 0, bw.err
+	}
+
+	if bw.gw == nil {
+		return 0, http.ErrBodyNotAllowed
+	}
+
+	return bw.gw.Write(b)
+}
+
+// Unwrap allows http.ResponseController to access the underlying
+// http.ResponseWriter.
+func (bw *ghostBodyResponseWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+func (bw *ghostBodyResponseWriter) close() {
+	bw.WriteHeader(http.StatusOK)
+
+	if bw.gw != nil {
+		_ = bw.gw.Close()
+	}
+}
+
+func bodyAllowedForStatus(code int) bool {
+	switch {
+	case code >= 100 && code <= 199:
+		return false
+	case code == http.StatusNoContent, code == http.StatusNotModified:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,208 @@
+package ghoststring_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingTransport struct {
+	reqBodies  [][]byte
+	respBodies [][]byte
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		rt.reqBodies = append(rt.reqBodies, b)
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = resp.Body.Close()
+
+	rt.respBodies = append(rt.respBodies, b)
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+
+	return resp, nil
+}
+
+func TestGhostBody(t *testing.T) {
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.body", "through the wire")
+	require.Nil(t, err)
+	require.Nil(t, ghoststring.SetGhostifyer(gh))
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		payload := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		payload["content_type"] = r.Header.Get("Content-Type")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		_ = json.NewEncoder(w).Encode(payload)
+	})
+
+	srv := httptest.NewServer(&ghoststring.GhostBodyHandler{Namespace: "test.body", Handler: echo})
+	defer srv.Close()
+
+	t.Run("round trip", func(t *testing.T) {
+		r := require.New(t)
+
+		rec := &recordingTransport{}
+		client := &http.Client{Transport: &ghoststring.GhostBodyTransport{Namespace: "test.body", Base: rec, Required: true}}
+
+		big := strings.Repeat("cordial ", ghoststring.StreamSegmentSize/4)
+
+		resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{"secret":"`+big+`"}`))
+		r.Nil(err)
+		defer resp.Body.Close()
+
+		r.Equal(http.StatusCreated, resp.StatusCode)
+		r.Equal("application/json", resp.Header.Get("Content-Type"))
+
+		payload := map[string]string{}
+		r.Nil(json.NewDecoder(resp.Body).Decode(&payload))
+		r.Equal(big, payload["secret"])
+		r.Equal("application/json", payload["content_type"])
+
+		r.Len(rec.reqBodies, 1)
+		r.NotContains(string(rec.reqBodies[0]), "cordial")
+		r.True(bytes.HasPrefix(rec.reqBodies[0], []byte(ghoststring.StreamPrefix)))
+
+		r.Len(rec.respBodies, 1)
+		r.NotContains(string(rec.respBodies[0]), "cordial")
+
+		req, err := http.NewRequest(http.MethodDelete, srv.URL, nil)
+		r.Nil(err)
+
+		resp, err = client.Do(req)
+		r.Nil(err)
+		r.Equal(http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("plain clients", func(t *testing.T) {
+		r := require.New(t)
+
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"secret":"open"}`))
+		r.Nil(err)
+		defer resp.Body.Close()
+
+		r.Equal("application/json", resp.Header.Get("Content-Type"))
+
+		payload := map[string]string{}
+		r.Nil(json.NewDecoder(resp.Body).Decode(&payload))
+		r.Equal("open", payload["secret"])
+
+		client := &http.Client{Transport: &ghoststring.GhostBodyTransport{Namespace: "test.body", Required: true}}
+
+		plain := httptest.NewServer(echo)
+		defer plain.Close()
+
+		_, err = client.Post(plain.URL, "application/json", strings.NewReader(`{"secret":"open"}`))
+		r.ErrorIs(err, ghoststring.Err)
+
+		keyless := httptest.NewServer(&ghoststring.GhostBodyHandler{Namespace: "test.body.keyless", Handler: echo})
+		defer keyless.Close()
+
+		resp, err = http.Post(keyless.URL, "application/json", strings.NewReader(`{"secret":"open"}`))
+		r.Nil(err)
+		_ = resp.Body.Close()
+		r.Equal(http.StatusCreated, resp.StatusCode)
+
+		resp, err = http.Post(keyless.URL, ghoststring.BodyContentType, strings.NewReader("not a stream"))
+		r.Nil(err)
+		_ = resp.Body.Close()
+		r.Equal(http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("required", func(t *testing.T) {
+		r := require.New(t)
+
+		required := httptest.NewServer(&ghoststring.GhostBodyHandler{Namespace: "test.body", Handler: echo, Required: true})
+		defer required.Close()
+
+		resp, err := http.Post(required.URL, "application/json", strings.NewReader(`{"secret":"open"}`))
+		r.Nil(err)
+		_ = resp.Body.Close()
+		r.Equal(http.StatusUnsupportedMediaType, resp.StatusCode)
+
+		resp, err = http.Post(required.URL, ghoststring.BodyContentType, strings.NewReader("not a stream"))
+		r.Nil(err)
+		_ = resp.Body.Close()
+		r.Equal(http.StatusBadRequest, resp.StatusCode)
+
+		client := &http.Client{Transport: &ghoststring.GhostBodyTransport{Namespace: "test.body"}}
+
+		resp, err = client.Post(required.URL, "application/json", strings.NewReader(`{"secret":"closed"}`))
+		r.Nil(err)
+		defer resp.Body.Close()
+
+		r.Equal(http.StatusCreated, resp.StatusCode)
+	})
+	t.Run("informational", func(t *testing.T) {
+		r := require.New(t)
+
+		hinted := httptest.NewServer(
+			&ghoststring.GhostBodyHandler{
+				Namespace: "test.body",
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Link", "</fjord.css>; rel=preload; as=style")
+					w.WriteHeader(http.StatusEarlyHints)
+
+					w.Header().Set("Content-Type", "text/plain")
+					w.WriteHeader(http.StatusCreated)
+
+					_, _ = io.WriteString(w, "hints taken")
+				}),
+			},
+		)
+		defer hinted.Close()
+
+		rec := &recordingTransport{}
+		client := &http.Client{Transport: &ghoststring.GhostBodyTransport{Namespace: "test.body", Base: rec, Required: true}}
+
+		resp, err := client.Get(hinted.URL)
+		r.Nil(err)
+		defer resp.Body.Close()
+
+		r.Equal(http.StatusCreated, resp.StatusCode)
+		r.Equal("text/plain", resp.Header.Get("Content-Type"))
+
+		b, err := io.ReadAll(resp.Body)
+		r.Nil(err)
+		r.Equal("hints taken", string(b))
+
+		r.Len(rec.respBodies, 1)
+		r.NotContains(string(rec.respBodies[0]), "hints taken")
+	})
+}
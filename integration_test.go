@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
@@ -17,8 +19,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/rstudio/ghoststring"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
 )
 
 var (
@@ -41,10 +45,10 @@ func getEphemeralAddr(t *testing.T) string {
 	return l.Addr().String()
 }
 
-func startIntegrationServer(ctx context.Context, t *testing.T, l *log.Logger, name string, env []string) *exec.Cmd {
+func startIntegrationServer(ctx context.Context, t *testing.T, l *log.Logger, name string, env []string, args ...string) *exec.Cmd {
 	buf := &bytes.Buffer{}
 
-	proc := exec.CommandContext(ctx, filepath.Join(top, "build", runtime.GOOS, runtime.GOARCH, name))
+	proc := exec.CommandContext(ctx, filepath.Join(top, "build", runtime.GOOS, runtime.GOARCH, name), args...)
 	proc.Env = env
 	proc.Stdout = buf
 	proc.Stderr = buf
@@ -230,8 +234,455 @@ func TestIntegrationViaClientSession(t *testing.T) {
 
 	r.Equal(int64(1218), mythRespBody["score"])
 
+	l.Println("repeating via GhostBodyTransport")
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("hightops", string(secretKeyBytes))
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	ghostClient := &http.Client{
+		Transport: &ghoststring.GhostBodyTransport{Namespace: "hightops", Required: true},
+	}
+
+	ghostRectResp, err := ghostClient.Get(rectURLString)
+	r.Nil(err)
+
+	defer ghostRectResp.Body.Close()
+
+	r.Equal("application/json", ghostRectResp.Header.Get("Content-Type"))
+
+	ghostRectBody := map[string]naiveShape{}
+	r.Nil(json.NewDecoder(ghostRectResp.Body).Decode(&ghostRectBody))
+	r.Equal(rectBody, ghostRectBody)
+
+	ghostMythResp, err := ghostClient.Post("http://127.0.0.1:"+mythPort, "application/json", bytes.NewReader(reqBytes))
+	r.Nil(err)
+
+	defer ghostMythResp.Body.Close()
+
+	ghostMythRespBody := map[string]int64{"score": 0}
+	r.Nil(json.NewDecoder(ghostMythResp.Body).Decode(&ghostMythRespBody))
+	r.Equal(int64(1218), ghostMythRespBody["score"])
+
+	rawReq, err := http.NewRequest(http.MethodGet, rectURLString, nil)
+	r.Nil(err)
+
+	rawReq.Header.Set(ghoststring.BodyAcceptHeader, "hightops")
+
+	rawResp, err := http.DefaultClient.Do(rawReq)
+	r.Nil(err)
+
+	defer rawResp.Body.Close()
+
+	r.Equal(ghoststring.BodyContentType, rawResp.Header.Get("Content-Type"))
+
 	l.Println("done with assertions")
 
 	killWaitProc(l, rectPort, rectProc)
 	killWaitProc(l, mythPort, mythProc)
 }
+
+func postServeJSON(client *http.Client, url, token string, reqBody any, respBody any) (int, error) {
+	reqBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return 0, err
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(respBody)
+}
+
+func TestIntegrationServe(t *testing.T) {
+	if os.Getenv("GHOSTSTRING_INTEGRATION_TESTING") != "on" {
+		t.SkipNow()
+	}
+
+	r := require.New(t)
+
+	localTmp := filepath.Join(top, ".local", "tmp")
+	r.Nil(os.MkdirAll(localTmp, 0755))
+
+	lf, err := os.Create(filepath.Join(localTmp, fmt.Sprintf("testlog.serve.%[1]v", time.Now().Unix())))
+	r.Nil(err)
+	defer func() { _ = lf.Close() }()
+
+	l := log.New(lf, "", log.LstdFlags)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	keys := []*ghoststring.TimestampedKey{{Timestamp: 1661351759000, Key: "sidecar secret"}}
+	retiredKeys := []*ghoststring.TimestampedKey{{Timestamp: 1629815759000, Key: "retired secret"}}
+
+	configBytes, err := json.Marshal(
+		map[string]any{
+			"namespaces": map[string]any{
+				"hightops": map[string]any{
+					"keys":          append(retiredKeys, keys...),
+					"bearer_tokens": []string{"hightops-token"},
+					"uids":          []int{os.Getuid()},
+				},
+				"lowtops": map[string]any{
+					"keys":          []*ghoststring.TimestampedKey{{Timestamp: 1661351759000, Key: "other secret"}},
+					"bearer_tokens": []string{"lowtops-token"},
+				},
+			},
+			"translations": []map[string]string{{"from": "hightops", "to": "lowtops"}},
+		},
+	)
+	r.Nil(err)
+
+	configPath := filepath.Join(localTmp, "serve.json")
+	r.Nil(os.WriteFile(configPath, configBytes, 0600))
+
+	socketPath := filepath.Join(localTmp, "serve.sock")
+	_ = os.Remove(socketPath)
+
+	serveAddr := getEphemeralAddr(t)
+
+	_, servePort, err := net.SplitHostPort(serveAddr)
+	r.Nil(err)
+
+	serveProc := startIntegrationServer(ctx, t, l, "ghoststring", os.Environ(), "serve", "-c", configPath, "-listen", serveAddr)
+	defer killWaitProc(l, servePort, serveProc)
+
+	r.Nil(waitForHealthy(ctx, l, servePort))
+
+	socketProc := startIntegrationServer(ctx, t, l, "ghoststring", os.Environ(), "serve", "-c", configPath, "-socket", socketPath)
+	defer func() {
+		_ = socketProc.Process.Kill()
+		_ = socketProc.Wait()
+	}()
+
+	serveURL := "http://127.0.0.1:" + servePort
+
+	resp, err := http.Get(serveURL + "/readiness")
+	r.Nil(err)
+	_ = resp.Body.Close()
+	r.Equal(http.StatusOK, resp.StatusCode)
+
+	encrypted := map[string]string{}
+
+	code, err := postServeJSON(
+		http.DefaultClient,
+		serveURL+"/encrypt",
+		"hightops-token",
+		map[string]any{"namespace": "hightops", "str": "kick flip"},
+		&encrypted,
+	)
+	r.Nil(err)
+	r.Equal(http.StatusOK, code)
+	r.Contains(encrypted["ghoststring"], ghoststring.Prefix)
+
+	ks, err := ghoststring.NewKeyStore("hightops", keys)
+	r.Nil(err)
+
+	gs, err := ghoststring.NewAES256GCMMultiKeyGhostifyer("hightops", ks).Unghostify(encrypted["ghoststring"])
+	r.Nil(err)
+	r.Equal("kick flip", gs.Str)
+
+	errBody := map[string]string{}
+
+	code, err = postServeJSON(http.DefaultClient, serveURL+"/decrypt", "", encrypted, &errBody)
+	r.Nil(err)
+	r.Equal(http.StatusUnauthorized, code)
+
+	code, err = postServeJSON(http.DefaultClient, serveURL+"/decrypt", "lowtops-token", encrypted, &errBody)
+	r.Nil(err)
+	r.Equal(http.StatusForbidden, code)
+
+	socketClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+
+	r.Eventually(func() bool {
+		resp, err := socketClient.Get("http://unix/healthcheck")
+		if err != nil {
+			return false
+		}
+
+		_ = resp.Body.Close()
+
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 10*time.Millisecond)
+
+	decrypted := map[string]any{}
+
+	code, err = postServeJSON(socketClient, "http://unix/decrypt", "", encrypted, &decrypted)
+	r.Nil(err)
+	r.Equal(http.StatusOK, code)
+	r.Equal("kick flip", decrypted["str"])
+	r.Equal("hightops", decrypted["namespace"])
+
+	rewrapped := map[string]any{}
+
+	code, err = postServeJSON(socketClient, "http://unix/rewrap", "", encrypted, &rewrapped)
+	r.Nil(err)
+	r.Equal(http.StatusOK, code)
+	r.Equal(encrypted["ghoststring"], rewrapped["ghoststring"])
+	r.Equal(false, rewrapped["changed"])
+
+	oldKS, err := ghoststring.NewKeyStore("hightops", retiredKeys)
+	r.Nil(err)
+
+	oldGS, err := ghoststring.NewAES256GCMMultiKeyGhostifyer("hightops", oldKS).Ghostify(
+		&ghoststring.GhostString{Namespace: "hightops", Str: "ollie"},
+	)
+	r.Nil(err)
+
+	code, err = postServeJSON(socketClient, "http://unix/rewrap", "", map[string]string{"ghoststring": oldGS}, &rewrapped)
+	r.Nil(err)
+	r.Equal(http.StatusOK, code)
+	r.Equal(true, rewrapped["changed"])
+
+	gs, err = ghoststring.NewAES256GCMMultiKeyGhostifyer("hightops", ks).Unghostify(rewrapped["ghoststring"].(string))
+	r.Nil(err)
+	r.Equal("ollie", gs.Str)
+
+	translated := map[string]string{}
+
+	code, err = postServeJSON(
+		http.DefaultClient,
+		serveURL+"/translate",
+		"hightops-token",
+		map[string]string{"ghoststring": encrypted["ghoststring"], "namespace": "lowtops"},
+		&translated,
+	)
+	r.Nil(err)
+	r.Equal(http.StatusOK, code)
+
+	code, err = postServeJSON(http.DefaultClient, serveURL+"/decrypt", "lowtops-token", translated, &decrypted)
+	r.Nil(err)
+	r.Equal(http.StatusOK, code)
+	r.Equal("kick flip", decrypted["str"])
+	r.Equal("lowtops", decrypted["namespace"])
+
+	code, err = postServeJSON(
+		http.DefaultClient,
+		serveURL+"/translate",
+		"lowtops-token",
+		map[string]string{"ghoststring": translated["ghoststring"], "namespace": "hightops"},
+		&errBody,
+	)
+	r.Nil(err)
+	r.Equal(http.StatusForbidden, code)
+}
+
+func TestIntegrationAgent(t *testing.T) {
+	if os.Getenv("GHOSTSTRING_INTEGRATION_TESTING") != "on" {
+		t.SkipNow()
+	}
+
+	r := require.New(t)
+
+	localTmp := filepath.Join(top, ".local", "tmp")
+	r.Nil(os.MkdirAll(localTmp, 0755))
+
+	lf, err := os.Create(filepath.Join(localTmp, fmt.Sprintf("testlog.agent.%[1]v", time.Now().Unix())))
+	r.Nil(err)
+	defer func() { _ = lf.Close() }()
+
+	l := log.New(lf, "", log.LstdFlags)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	socketPath := filepath.Join(localTmp, "agent.sock")
+	_ = os.Remove(socketPath)
+
+	agentProc := startIntegrationServer(
+		ctx, t, l, "ghoststring",
+		append(os.Environ(), `GHOSTSTRING_KEY_HIGHTOPS_0={"timestamp":1661351759000,"key":"agent of change"}`),
+		"agent", "-n", "hightops", "-socket", socketPath,
+	)
+	defer func() {
+		_ = agentProc.Process.Kill()
+		_ = agentProc.Wait()
+	}()
+
+	gh, err := ghoststring.NewAgentGhostifyer("hightops", socketPath)
+	r.Nil(err)
+
+	var s string
+
+	r.Eventually(func() bool {
+		s, err = gh.Ghostify(&ghoststring.GhostString{Namespace: "hightops", Str: "double agent", MaxAge: time.Hour})
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	local, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("hightops", "agent of change")
+	r.Nil(err)
+
+	gs, err := local.Unghostify(s)
+	r.Nil(err)
+	r.Equal("double agent", gs.Str)
+
+	gs, err = gh.Unghostify(s)
+	r.Nil(err)
+	r.Equal("double agent", gs.Str)
+	r.Equal(time.Hour, gs.MaxAge)
+	r.False(gs.IssuedAt.IsZero())
+
+	cli := exec.CommandContext(ctx, filepath.Join(top, "build", runtime.GOOS, runtime.GOARCH, "ghoststring"), "-n", "hightops", "-d")
+	cli.Env = append(os.Environ(), ghoststring.AgentSocketEnv+"="+socketPath)
+	cli.Stdin = strings.NewReader(s)
+
+	out, err := cli.Output()
+	r.Nil(err)
+	r.Equal("double agent", string(out))
+}
+
+func TestIntegrationPlugin(t *testing.T) {
+	if os.Getenv("GHOSTSTRING_INTEGRATION_TESTING") != "on" {
+		t.SkipNow()
+	}
+
+	r := require.New(t)
+
+	gh, err := ghoststring.NewPluginGhostifyer(
+		"hightops",
+		[]string{filepath.Join(top, "build", runtime.GOOS, runtime.GOARCH, "lockbox")},
+		ghoststring.WithPluginEnv([]string{`GHOSTSTRING_KEY_HIGHTOPS_0={"timestamp":1661351759000,"key":"in custody"}`}),
+	)
+	r.Nil(err)
+	defer func() { r.Nil(gh.(io.Closer).Close()) }()
+
+	local, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("hightops", "in custody")
+	r.Nil(err)
+
+	for _, str := range []string{"first", "second", "third"} {
+		s, err := gh.Ghostify(&ghoststring.GhostString{Namespace: "hightops", Str: str, TokenID: "t-" + str})
+		r.Nil(err)
+
+		gs, err := local.Unghostify(s)
+		r.Nil(err)
+		r.Equal(str, gs.Str)
+
+		gs, err = gh.Unghostify(s)
+		r.Nil(err)
+		r.Equal(str, gs.Str)
+		r.Equal("t-"+str, gs.TokenID)
+	}
+
+	expired, err := local.Ghostify(
+		&ghoststring.GhostString{
+			Namespace: "hightops",
+			Str:       "stale",
+			IssuedAt:  time.Now().Add(-time.Hour),
+			MaxAge:    time.Minute,
+		},
+	)
+	r.Nil(err)
+
+	_, err = gh.Unghostify(expired)
+	r.ErrorIs(err, ghoststring.ErrExpired)
+
+	other, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("lowtops", "in custody")
+	r.Nil(err)
+
+	s, err := other.Ghostify(&ghoststring.GhostString{Namespace: "lowtops", Str: "elsewhere"})
+	r.Nil(err)
+
+	_, err = gh.Unghostify(s)
+	r.ErrorIs(err, ghoststring.Err)
+}
+
+func TestIntegrationRewrapSQL(t *testing.T) {
+	if os.Getenv("GHOSTSTRING_INTEGRATION_TESTING") != "on" {
+		t.SkipNow()
+	}
+
+	r := require.New(t)
+
+	localTmp := filepath.Join(top, ".local", "tmp")
+	r.Nil(os.MkdirAll(localTmp, 0755))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dbPath := filepath.Join(localTmp, "rewrap.db")
+	checkpointPath := filepath.Join(localTmp, "rewrap.checkpoint")
+
+	for _, path := range []string{dbPath, checkpointPath} {
+		_ = os.Remove(path)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	r.Nil(err)
+	defer func() { _ = db.Close() }()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE shoes (id INTEGER PRIMARY KEY, secret TEXT)")
+	r.Nil(err)
+
+	old, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("hightops", "laces undone")
+	r.Nil(err)
+
+	for i := 1; i <= 5; i++ {
+		s, err := old.Ghostify(&ghoststring.GhostString{Namespace: "hightops", Str: fmt.Sprintf("pair %d", i)})
+		r.Nil(err)
+
+		_, err = db.ExecContext(ctx, "INSERT INTO shoes (id, secret) VALUES (?, ?)", i, s)
+		r.Nil(err)
+	}
+
+	cli := exec.CommandContext(
+		ctx,
+		filepath.Join(top, "build", runtime.GOOS, runtime.GOARCH, "ghoststring-rewrap-sql"),
+		"-n", "hightops", "-dsn", dbPath, "-table", "shoes", "-columns", "secret",
+		"-batch", "2", "-concurrency", "2", "-checkpoint", checkpointPath,
+	)
+	cli.Env = append(
+		os.Environ(),
+		`GHOSTSTRING_KEY_HIGHTOPS_0={"timestamp":1661351759000,"key":"laces undone"}`,
+		`GHOSTSTRING_KEY_HIGHTOPS_1={"timestamp":1692887759000,"key":"double knotted"}`,
+	)
+
+	out, err := cli.CombinedOutput()
+	r.Nil(err, string(out))
+	r.Contains(string(out), "rewrapped=5")
+
+	checkpointBytes, err := os.ReadFile(checkpointPath)
+	r.Nil(err)
+	r.Equal("5\n", string(checkpointBytes))
+
+	latest, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("hightops", "double knotted")
+	r.Nil(err)
+
+	rows, err := db.QueryContext(ctx, "SELECT id, secret FROM shoes ORDER BY id")
+	r.Nil(err)
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var (
+			id     int
+			secret string
+		)
+
+		r.Nil(rows.Scan(&id, &secret))
+
+		gs, err := latest.Unghostify(secret)
+		r.Nil(err)
+		r.Equal(fmt.Sprintf("pair %d", id), gs.Str)
+	}
+
+	r.Nil(rows.Err())
+}
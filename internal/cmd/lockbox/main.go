@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+
+	"github.com/rstudio/ghoststring"
+)
+
+type request struct {
+	ID uint64 `json:"id"`
+	Op string `json:"op"`
+
+	ghoststring.GhostStringFields
+
+	GhostString string `json:"ghoststring,omitempty"`
+}
+
+type response struct {
+	ID uint64 `json:"id"`
+
+	ghoststring.GhostStringFields
+
+	GhostString string         `json:"ghoststring,omitempty"`
+	Error       *responseError `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// main serves as a reference plugin for
+// ghoststring.NewPluginGhostifyer that keeps its keys, read from
+// the environment as described by ghoststring.EnvKeyStoreKeyPrefix,
+// out of the calling process.
+func main() {
+	ghostifyers := map[string]ghoststring.Ghostifyer{}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	enc := json.NewEncoder(os.Stdout)
+
+	for scanner.Scan() {
+		req := &request{}
+		if err := json.Unmarshal(scanner.Bytes(), req); err != nil {
+			log.Fatal(err)
+		}
+
+		resp := handle(ghostifyers, req)
+		resp.ID = req.ID
+
+		if err := enc.Encode(resp); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func handle(ghostifyers map[string]ghoststring.Ghostifyer, req *request) *response {
+	gh, ok := ghostifyers[req.Namespace]
+	if !ok {
+		ks, err := ghoststring.NewKeyStoreFromEnv(req.Namespace, nil)
+		if err != nil {
+			return toErrorResponse(err)
+		}
+
+		gh = ghoststring.NewAES256GCMMultiKeyGhostifyer(req.Namespace, ks)
+		ghostifyers[req.Namespace] = gh
+	}
+
+	switch req.Op {
+	case "ghostify":
+		s, err := gh.(ghoststring.HeaderGhostifyer).GhostifyWithHeader(req.GhostStringFields.GhostString(), req.Header)
+		if err != nil {
+			return toErrorResponse(err)
+		}
+
+		return &response{GhostString: s}
+	case "unghostify":
+		un, err := gh.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(req.GhostString)
+		if err != nil {
+			return toErrorResponse(err)
+		}
+
+		if un.GhostString.Namespace != req.Namespace {
+			return &response{Error: &responseError{Kind: "invalid", Message: "namespace mismatch"}}
+		}
+
+		return &response{GhostStringFields: ghoststring.NewGhostStringFields(un.GhostString, un.UserHeader())}
+	}
+
+	return &response{Error: &responseError{Kind: "unsupported", Message: req.Op}}
+}
+
+func toErrorResponse(err error) *response {
+	kind := "invalid"
+
+	switch {
+	case errors.Is(err, ghoststring.ErrExpired):
+		kind = ghoststring.PluginErrorExpired
+	case errors.Is(err, ghoststring.ErrReplayed):
+		kind = ghoststring.PluginErrorReplayed
+	case errors.Is(err, ghoststring.ErrAudience):
+		kind = ghoststring.PluginErrorAudience
+	}
+
+	return &response{Error: &responseError{Kind: kind, Message: err.Error()}}
+}
@@ -108,7 +108,7 @@ func main() {
 
 		log.Printf("listening at %[1]q", addr)
 
-		if err := http.ListenAndServe(addr, appFunc); err != nil {
+		if err := http.ListenAndServe(addr, &ghoststring.GhostBodyHandler{Namespace: "hightops", Handler: appFunc}); err != nil {
 			log.Printf("OH NO: %[1]v", err)
 		}
 	}()
@@ -8,6 +8,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -18,6 +19,8 @@ const (
 
 var (
 	envKeyStoreKeyPrefixTmpl = template.Must(template.New("key_prefix").Parse(EnvKeyStoreKeyPrefix))
+
+	_ TimestampedKeyStore = &inMemoryKeyStore{}
 )
 
 type KeyStore interface {
@@ -25,6 +28,17 @@ type KeyStore interface {
 	All(ctx context.Context) ([][]byte, error)
 }
 
+// TimestampedKeyStore is implemented by KeyStores that are able to
+// report the timestamps of their keys, such as those created via
+// NewKeyStore.
+type TimestampedKeyStore interface {
+	KeyStore
+
+	// Timestamps returns the timestamps of the keys in the same
+	// order as All.
+	Timestamps(ctx context.Context) ([]time.Time, error)
+}
+
 func NewKeyStore(namespace string, keys []*TimestampedKey) (KeyStore, error) {
 	if len(keys) == 0 {
 		return nil, errors.Wrap(Err, "no keys found")
@@ -109,3 +123,19 @@ func (ks *inMemoryKeyStore) All(context.Context) ([][]byte, error) {
 
 	return sl, nil
 }
+
+func (ks *inMemoryKeyStore) Timestamps(context.Context) ([]time.Time, error) {
+	if ks.keys.Len() == 0 {
+		return nil, errors.Wrap(Err, "no keys available")
+	}
+
+	sort.Sort(sort.Reverse(ks.keys))
+
+	sl := make([]time.Time, ks.keys.Len())
+
+	for i, tk := range ks.keys {
+		sl[i] = time.UnixMilli(tk.Timestamp)
+	}
+
+	return sl, nil
+}
@@ -0,0 +1,243 @@
+package ghoststring
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	_ json.Marshaler           = &LazyGhostString{}
+	_ json.Unmarshaler         = &LazyGhostString{}
+	_ fmt.Stringer             = &LazyGhostString{}
+	_ fmt.GoStringer           = &LazyGhostString{}
+	_ encoding.TextMarshaler   = &LazyGhostString{}
+	_ encoding.TextUnmarshaler = &LazyGhostString{}
+)
+
+// LazyGhostString is a variant of GhostString that holds only the
+// ghostified string when unmarshaled, deferring decryption until
+// Reveal is called. Marshaling a LazyGhostString writes the held
+// ghostified string back unchanged.
+type LazyGhostString struct {
+	state *lazyState
+
+	noRevealCaching bool
+}
+
+type lazyState struct {
+	s         string
+	namespace string
+
+	lock     sync.Mutex
+	revealed *GhostString
+}
+
+// SetRevealCaching enables or disables caching of the plain text
+// revealed by Reveal for this value. When disabled, any cached plain
+// text is discarded, every call to Reveal decrypts the held
+// ghostified string, and the plain text is never retained. Because
+// each Reveal is then a separate unghostify, a value carrying a
+// TokenID may be revealed only once when its Ghostifyer is
+// configured with a ReplayCache, after which Reveal returns
+// ErrReplayed. Caching is enabled by default.
+func (lgs *LazyGhostString) SetRevealCaching(enabled bool) {
+	lgs.noRevealCaching = !enabled
+
+	if !enabled {
+		lgs.Forget()
+	}
+}
+
+// NewLazyGhostString wraps a ghostified string without decrypting
+// it.
+func NewLazyGhostString(s string) (*LazyGhostString, error) {
+	lgs := &LazyGhostString{}
+	if err := lgs.fromString(s); err != nil {
+		return nil, err
+	}
+
+	return lgs, nil
+}
+
+// Namespace returns the namespace of the held ghostified string,
+// which is available without decryption.
+func (lgs *LazyGhostString) Namespace() string {
+	if lgs.state == nil {
+		return ""
+	}
+
+	return lgs.state.namespace
+}
+
+// Reveal decrypts the held ghostified string with the Ghostifyer
+// registered for its namespace and returns the plain text. See
+// SetRevealCaching.
+func (lgs *LazyGhostString) Reveal(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if lgs.state == nil {
+		return "", nil
+	}
+
+	lgs.state.lock.Lock()
+	defer lgs.state.lock.Unlock()
+
+	if lgs.state.revealed != nil {
+		return lgs.state.revealed.Str, nil
+	}
+
+	if lgs.state.s == "" {
+		return "", errors.Wrapf(Err, "forgotten legacy plaintext in namespace %[1]q", lgs.state.namespace)
+	}
+
+	un, err := metaUnghostify(lgs.state.s)
+	if err != nil {
+		return "", err
+	}
+
+	if !lgs.noRevealCaching {
+		lgs.state.revealed = un.GhostString
+	}
+
+	return un.GhostString.Str, nil
+}
+
+// Forget discards any cached plain text, including that of a legacy
+// plaintext value that could not be ghostified when unmarshaled,
+// which can then no longer be revealed.
+func (lgs *LazyGhostString) Forget() {
+	if lgs.state == nil {
+		return
+	}
+
+	lgs.state.lock.Lock()
+	lgs.state.revealed = nil
+	lgs.state.lock.Unlock()
+}
+
+func (lgs *LazyGhostString) String() string {
+	s, err := lgs.toString()
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+func (lgs *LazyGhostString) GoString() string {
+	return fmt.Sprintf(
+		"{%q, %q}",
+		lgs.Namespace(),
+		lgs.String(),
+	)
+}
+
+// MarshalJSON allows LazyGhostString to fulfill the json.Marshaler
+// interface.
+func (lgs *LazyGhostString) MarshalJSON() ([]byte, error) {
+	s, err := lgs.toString()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON allows LazyGhostString to fulfill the
+// json.Unmarshaler interface. Unlike GhostString, no decryption
+// occurs.
+func (lgs *LazyGhostString) UnmarshalJSON(b []byte) error {
+	s := ""
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	return lgs.fromString(s)
+}
+
+// MarshalText allows LazyGhostString to fulfill the
+// encoding.TextMarshaler interface.
+func (lgs *LazyGhostString) MarshalText() ([]byte, error) {
+	s, err := lgs.toString()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(s), nil
+}
+
+// UnmarshalText allows LazyGhostString to fulfill the
+// encoding.TextUnmarshaler interface. Unlike GhostString, no
+// decryption occurs.
+func (lgs *LazyGhostString) UnmarshalText(b []byte) error {
+	return lgs.fromString(string(b))
+}
+
+func (lgs *LazyGhostString) toString() (string, error) {
+	if lgs.state == nil {
+		return "", nil
+	}
+
+	lgs.state.lock.Lock()
+	defer lgs.state.lock.Unlock()
+
+	if lgs.state.s != "" || lgs.state.revealed == nil {
+		return lgs.state.s, nil
+	}
+
+	return lgs.state.revealed.toString()
+}
+
+func (lgs *LazyGhostString) fromString(s string) error {
+	if s == "" {
+		lgs.state = nil
+
+		return nil
+	}
+
+	if lp := toLegacyPlaintext(s); lp != nil {
+		return lgs.fromLegacyPlaintext(lp)
+	}
+
+	unParts, err := toUnghostifyParts(s)
+	if err != nil {
+		return err
+	}
+
+	lgs.state = &lazyState{s: s, namespace: unParts.namespace}
+
+	return nil
+}
+
+// fromLegacyPlaintext ghostifies the legacy plaintext value right
+// away so that the plain text is held only as for any other
+// ghostified string. Without a Ghostifyer for the legacy plaintext
+// namespace, the plain text is held until Forget is called, and
+// with reveal caching disabled the value cannot be held at all.
+func (lgs *LazyGhostString) fromLegacyPlaintext(lp *GhostString) error {
+	s, err := lp.toString()
+	if err != nil {
+		return err
+	}
+
+	if hasGhostifiedPrefix(s) {
+		lgs.state = &lazyState{s: s, namespace: lp.Namespace}
+
+		return nil
+	}
+
+	if lgs.noRevealCaching {
+		return errors.Wrapf(Err, "no ghostifyer set for namespace %[1]q", lp.Namespace)
+	}
+
+	lgs.state = &lazyState{namespace: lp.Namespace, revealed: lp}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package ghoststring_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazyGhostString(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.lazy", "maybe later")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	type eager struct {
+		Secret ghoststring.GhostString `json:"secret"`
+		Other  ghoststring.GhostString `json:"other"`
+	}
+
+	type lazy struct {
+		Secret ghoststring.LazyGhostString `json:"secret"`
+		Other  ghoststring.LazyGhostString `json:"other"`
+	}
+
+	b, err := json.Marshal(
+		&eager{
+			Secret: ghoststring.GhostString{Namespace: "test.lazy", Str: "procrastinate"},
+		},
+	)
+	r.Nil(err)
+
+	l := &lazy{}
+	r.Nil(json.Unmarshal(b, l))
+
+	r.Equal("test.lazy", l.Secret.Namespace())
+	r.Equal("", l.Other.Namespace())
+
+	again, err := json.Marshal(l)
+	r.Nil(err)
+	r.Equal(string(b), string(again))
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		s, err := l.Secret.Reveal(ctx)
+		r.Nil(err)
+		r.Equal("procrastinate", s)
+	}
+
+	s, err := l.Other.Reveal(ctx)
+	r.Nil(err)
+	r.Equal("", s)
+
+	l.Secret.Forget()
+	l.Secret.SetRevealCaching(false)
+
+	for i := 0; i < 2; i++ {
+		s, err = l.Secret.Reveal(ctx)
+		r.Nil(err)
+		r.Equal("procrastinate", s)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = l.Secret.Reveal(canceled)
+	r.ErrorIs(err, context.Canceled)
+
+	unregistered, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.lazy.unregistered", "never")
+	r.Nil(err)
+
+	unregisteredStr, err := unregistered.Ghostify(
+		&ghoststring.GhostString{Namespace: "test.lazy.unregistered", Str: "nope"},
+	)
+	r.Nil(err)
+
+	lgs, err := ghoststring.NewLazyGhostString(unregisteredStr)
+	r.Nil(err)
+	r.Equal("test.lazy.unregistered", lgs.Namespace())
+	r.Equal(unregisteredStr, lgs.String())
+
+	_, err = lgs.Reveal(ctx)
+	r.ErrorIs(err, ghoststring.Err)
+
+	_, err = ghoststring.NewLazyGhostString(ghoststring.Prefix + "nope")
+	r.NotNil(err)
+}
+
+func TestLazyGhostString_RevealCaching(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+		"test.lazy.once",
+		"only once",
+		ghoststring.WithReplayCache(ghoststring.NewInMemoryReplayCache(time.Minute)),
+	)
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	tokenID, err := ghoststring.NewTokenID()
+	r.Nil(err)
+
+	ghostified := (&ghoststring.GhostString{Namespace: "test.lazy.once", Str: "one shot", TokenID: tokenID}).String()
+
+	ctx := context.Background()
+
+	cached, err := ghoststring.NewLazyGhostString(ghostified)
+	r.Nil(err)
+
+	uncached, err := ghoststring.NewLazyGhostString(ghostified)
+	r.Nil(err)
+	uncached.SetRevealCaching(false)
+
+	for i := 0; i < 2; i++ {
+		s, err := cached.Reveal(ctx)
+		r.Nil(err)
+		r.Equal("one shot", s)
+	}
+
+	_, err = uncached.Reveal(ctx)
+	r.ErrorIs(err, ghoststring.ErrReplayed)
+}
+
+func TestLazyGhostString_LegacyPlaintext(t *testing.T) {
+	r := require.New(t)
+
+	r.Nil(ghoststring.SetLegacyPlaintextNamespace("test.lazy.legacy"))
+	defer func() { _ = ghoststring.SetLegacyPlaintextNamespace("") }()
+
+	ctx := context.Background()
+
+	unregistered, err := ghoststring.NewLazyGhostString("from before")
+	r.Nil(err)
+
+	s, err := unregistered.Reveal(ctx)
+	r.Nil(err)
+	r.Equal("from before", s)
+
+	unregistered.Forget()
+
+	_, err = unregistered.Reveal(ctx)
+	r.ErrorIs(err, ghoststring.Err)
+
+	uncached := &ghoststring.LazyGhostString{}
+	uncached.SetRevealCaching(false)
+	r.ErrorIs(uncached.UnmarshalText([]byte("from before")), ghoststring.Err)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.lazy.legacy", "from now on")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	r.Nil(uncached.UnmarshalText([]byte("from before")))
+
+	b, err := uncached.MarshalText()
+	r.Nil(err)
+	r.NotContains(string(b), "from before")
+	r.Contains(string(b), ghoststring.Prefix)
+
+	s, err = uncached.Reveal(ctx)
+	r.Nil(err)
+	r.Equal("from before", s)
+
+	again, err := uncached.MarshalText()
+	r.Nil(err)
+	r.Equal(string(b), string(again))
+}
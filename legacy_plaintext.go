@@ -0,0 +1,51 @@
+package ghoststring
+
+import (
+	"sync/atomic"
+)
+
+var (
+	legacyPlaintextNamespace = &atomic.Value{}
+)
+
+// SetLegacyPlaintextNamespace enables migration of values that were
+// stored as plain strings before being converted to GhostString.
+// When set to a non-empty namespace, unmarshaling a value that lacks
+// the Prefix, ASCIIPrefix, or URLPrefix accepts it as the plain text Str in that
+// namespace rather than returning an error, and WasPlaintext
+// reports true so that such values may be counted and
+// re-ghostified. An empty namespace disables migration, which is
+// the default.
+func SetLegacyPlaintextNamespace(namespace string) error {
+	if namespace != "" {
+		if err := validateNamespace(namespace); err != nil {
+			return err
+		}
+	}
+
+	legacyPlaintextNamespace.Store(namespace)
+
+	return nil
+}
+
+// WasPlaintext checks if the GhostString was unmarshaled from a
+// legacy plain text value. See SetLegacyPlaintextNamespace.
+func (gs *GhostString) WasPlaintext() bool {
+	return gs.wasPlaintext
+}
+
+// toLegacyPlaintext returns a GhostString holding the string as its
+// plain text if migration is enabled and the string lacks the
+// prefix of any Encoding, otherwise nil.
+func toLegacyPlaintext(s string) *GhostString {
+	namespace, _ := legacyPlaintextNamespace.Load().(string)
+	if namespace == "" || hasGhostifiedPrefix(s) {
+		return nil
+	}
+
+	return &GhostString{
+		Namespace:    namespace,
+		Str:          s,
+		wasPlaintext: true,
+	}
+}
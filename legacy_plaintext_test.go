@@ -0,0 +1,54 @@
+package ghoststring_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostString_LegacyPlaintext(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.legacy", "out with the old")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(gh))
+
+	type row struct {
+		ID     int                     `json:"id"`
+		Secret ghoststring.GhostString `json:"secret"`
+	}
+
+	encrypted, err := json.Marshal(
+		&row{
+			ID:     2,
+			Secret: ghoststring.GhostString{Namespace: "test.legacy", Str: "in with the new"},
+		},
+	)
+	r.Nil(err)
+
+	legacy := []byte(`{"id":1,"secret":"in with the old"}`)
+
+	r.NotNil(json.Unmarshal(legacy, &row{}))
+
+	r.ErrorIs(ghoststring.SetLegacyPlaintextNamespace("no"), ghoststring.Err)
+	r.Nil(ghoststring.SetLegacyPlaintextNamespace("test.legacy"))
+	defer func() { _ = ghoststring.SetLegacyPlaintextNamespace("") }()
+
+	legacyRow := &row{}
+	r.Nil(json.Unmarshal(legacy, legacyRow))
+	r.Equal("in with the old", legacyRow.Secret.Str)
+	r.Equal("test.legacy", legacyRow.Secret.Namespace)
+	r.True(legacyRow.Secret.WasPlaintext())
+
+	reencrypted, err := json.Marshal(legacyRow)
+	r.Nil(err)
+	r.NotContains(string(reencrypted), "in with the old")
+	r.Contains(string(reencrypted), ghoststring.Prefix)
+
+	encryptedRow := &row{}
+	r.Nil(json.Unmarshal(encrypted, encryptedRow))
+	r.Equal("in with the new", encryptedRow.Secret.Str)
+	r.False(encryptedRow.Secret.WasPlaintext())
+}
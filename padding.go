@@ -0,0 +1,114 @@
+package ghoststring
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	paddingISO7816 = "iso7816"
+
+	paddingMarker = 0x80
+
+	// MaxPowerOfTwoPadding is the largest length to which
+	// PadToPowerOfTwo pads values.
+	MaxPowerOfTwoPadding = 1 << 30
+)
+
+// PaddingPolicy determines the padded length of a value of the
+// given length, which includes the single byte that marks the
+// start of the padding, returning an error if the value may not be
+// padded.
+type PaddingPolicy func(length int) (int, error)
+
+// PadToBucket pads values to the next multiple of size bytes.
+func PadToBucket(size int) PaddingPolicy {
+	return func(length int) (int, error) {
+		if size <= 0 {
+			return 0, errors.Wrapf(Err, "invalid padding bucket size %[1]v", size)
+		}
+
+		return ((length + size - 1) / size) * size, nil
+	}
+}
+
+// PadToPowerOfTwo pads values to the next power of two bytes, and
+// to at least minLength bytes, which limits the overhead of padding
+// for long values at the cost of revealing their approximate
+// length. Values that would be padded beyond MaxPowerOfTwoPadding
+// are rejected.
+func PadToPowerOfTwo(minLength int) PaddingPolicy {
+	return func(length int) (int, error) {
+		if length > MaxPowerOfTwoPadding || minLength > MaxPowerOfTwoPadding {
+			return 0, errors.Wrapf(Err, "value exceeds max padding length %[1]v", MaxPowerOfTwoPadding)
+		}
+
+		padded := 1
+		for padded < length || padded < minLength {
+			padded <<= 1
+		}
+
+		return padded, nil
+	}
+}
+
+// PadToFixed pads every value to exactly length bytes, so that all
+// values have the same ghostified length. Values that are too long
+// to fit are rejected rather than revealing their length.
+func PadToFixed(length int) PaddingPolicy {
+	return func(valueLength int) (int, error) {
+		if valueLength > length {
+			return 0, errors.Wrapf(Err, "value exceeds fixed padding length %[1]v", length)
+		}
+
+		return length, nil
+	}
+}
+
+// padPayload pads the payload according to the policy, if any,
+// flagging the padding in the header. The padding is a single
+// marker byte followed by zero bytes, as in ISO/IEC 7816-4.
+func padPayload(payload []byte, header Header, opts *ghostifyerOptions) ([]byte, error) {
+	if opts.padding == nil {
+		return payload, nil
+	}
+
+	padded, err := opts.padding(len(payload) + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if padded < len(payload)+1 {
+		return nil, errors.Wrapf(Err, "invalid padded length %[1]v", padded)
+	}
+
+	header[HeaderPadding] = paddingISO7816
+
+	out := make([]byte, padded)
+	copy(out, payload)
+	out[len(payload)] = paddingMarker
+
+	return out, nil
+}
+
+// unpadPayload removes the padding if flagged in the header.
+func unpadPayload(payload []byte, header Header) ([]byte, error) {
+	padding, ok := header[HeaderPadding]
+	if !ok {
+		return payload, nil
+	}
+
+	if padding != paddingISO7816 {
+		return nil, errors.Wrapf(Err, "unsupported padding %[1]q", padding)
+	}
+
+	i := len(payload) - 1
+	for i >= 0 && payload[i] == 0 {
+		i--
+	}
+
+	if i < 0 || payload[i] != paddingMarker {
+		return nil, errors.Wrap(Err, "invalid padding")
+	}
+
+	return payload[:i], nil
+}
@@ -0,0 +1,104 @@
+package ghoststring_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostString_Padding(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		policy  ghoststring.PaddingPolicy
+		samples []string
+	}{
+		{
+			name:    "bucket",
+			policy:  ghoststring.PadToBucket(32),
+			samples: []string{"", "y", "yes", "no", "giddy", strings.Repeat("m", 31)},
+		},
+		{
+			name:    "power of two",
+			policy:  ghoststring.PadToPowerOfTwo(16),
+			samples: []string{"", "y", "no", "giddy", strings.Repeat("m", 15)},
+		},
+		{
+			name:    "fixed",
+			policy:  ghoststring.PadToFixed(64),
+			samples: []string{"", "y", "no", "giddy", strings.Repeat("m", 63)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+
+			gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+				"test.padding",
+				"hushed",
+				ghoststring.WithPadding(tc.policy),
+				ghoststring.WithEmptyStrings(true),
+			)
+			r.Nil(err)
+
+			plain, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.padding", "hushed")
+			r.Nil(err)
+
+			lengths := map[int]bool{}
+
+			for _, sample := range tc.samples {
+				ghostStr, err := gh.Ghostify(&ghoststring.GhostString{Namespace: "test.padding", Str: sample})
+				r.Nil(err)
+
+				lengths[len(ghostStr)] = true
+
+				header, err := ghoststring.PeekHeader(ghostStr)
+				r.Nil(err)
+				r.Contains(header, ghoststring.HeaderPadding)
+
+				for _, un := range []ghoststring.Ghostifyer{gh, plain} {
+					detailed, err := un.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(ghostStr)
+					r.Nil(err)
+					r.Equal(sample, detailed.GhostString.Str)
+					r.Nil(detailed.UserHeader())
+				}
+			}
+
+			r.Len(lengths, 1)
+		})
+	}
+
+	t.Run("too long for fixed", func(t *testing.T) {
+		r := require.New(t)
+
+		gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+			"test.padding",
+			"hushed",
+			ghoststring.WithPadding(ghoststring.PadToFixed(8)),
+		)
+		r.Nil(err)
+
+		_, err = gh.Ghostify(&ghoststring.GhostString{Namespace: "test.padding", Str: "eight ch"})
+		r.ErrorIs(err, ghoststring.Err)
+	})
+	t.Run("too long for power of two", func(t *testing.T) {
+		r := require.New(t)
+
+		padded, err := ghoststring.PadToPowerOfTwo(16)(ghoststring.MaxPowerOfTwoPadding)
+		r.Nil(err)
+		r.Equal(ghoststring.MaxPowerOfTwoPadding, padded)
+
+		for _, tc := range []struct {
+			minLength int
+			length    int
+		}{
+			{minLength: 16, length: ghoststring.MaxPowerOfTwoPadding + 1},
+			{minLength: 16, length: math.MaxInt},
+			{minLength: math.MaxInt, length: 1},
+		} {
+			_, err := ghoststring.PadToPowerOfTwo(tc.minLength)(tc.length)
+			r.ErrorIs(err, ghoststring.Err)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package ghoststring
+
+import (
+	"sync/atomic"
+)
+
+var (
+	passthroughEnabled = &atomic.Bool{}
+)
+
+// SetPassthrough enables or disables passthrough of values in
+// namespaces without a registered Ghostifyer. When enabled,
+// unmarshaling such a value keeps the original ghostified string
+// opaque inside the GhostString rather than returning an error,
+// and marshaling writes it back unchanged for as long as Str
+// remains empty and the Namespace is unchanged. This allows
+// systems such as gateways to relay values that they are unable
+// to read.
+func SetPassthrough(enabled bool) {
+	passthroughEnabled.Store(enabled)
+}
+
+type passthroughValue struct {
+	s         string
+	namespace string
+}
+
+// IsPassthrough checks if the GhostString holds an opaque
+// ghostified string from a namespace without a registered
+// Ghostifyer. See SetPassthrough.
+func (gs *GhostString) IsPassthrough() bool {
+	return gs.passthrough != nil &&
+		gs.Str == "" &&
+		gs.Namespace == gs.passthrough.namespace
+}
+
+// toPassthrough returns a GhostString holding the opaque string if
+// passthrough is enabled and no Ghostifyer is registered for its
+// namespace, otherwise nil.
+func toPassthrough(s string) *GhostString {
+	if !passthroughEnabled.Load() {
+		return nil
+	}
+
+	unParts, err := toUnghostifyParts(s)
+	if err != nil {
+		return nil
+	}
+
+	if _, ok := getGhostifyer(unParts.namespace); ok {
+		return nil
+	}
+
+	return &GhostString{
+		Namespace: unParts.namespace,
+		passthrough: &passthroughValue{
+			s:         s,
+			namespace: unParts.namespace,
+		},
+	}
+}
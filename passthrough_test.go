@@ -0,0 +1,76 @@
+package ghoststring_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostString_Passthrough(t *testing.T) {
+	r := require.New(t)
+
+	known, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.relay.known", "open sesame")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(known))
+
+	unknown, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.relay.unknown", "closed sesame")
+	r.Nil(err)
+
+	unknownStr, err := unknown.Ghostify(
+		&ghoststring.GhostString{
+			Namespace: "test.relay.unknown",
+			Str:       "for your eyes only",
+		},
+	)
+	r.Nil(err)
+
+	type message struct {
+		Known   ghoststring.GhostString `json:"known"`
+		Unknown ghoststring.GhostString `json:"unknown"`
+	}
+
+	original, err := json.Marshal(
+		map[string]any{
+			"known": &ghoststring.GhostString{
+				Namespace: "test.relay.known",
+				Str:       "for anyone",
+			},
+			"unknown": unknownStr,
+		},
+	)
+	r.Nil(err)
+
+	r.ErrorIs(json.Unmarshal(original, &message{}), ghoststring.Err)
+
+	ghoststring.SetPassthrough(true)
+	defer ghoststring.SetPassthrough(false)
+
+	msg := &message{}
+	r.Nil(json.Unmarshal(original, msg))
+
+	r.Equal("for anyone", msg.Known.Str)
+	r.False(msg.Known.IsPassthrough())
+
+	r.Equal("", msg.Unknown.Str)
+	r.Equal("test.relay.unknown", msg.Unknown.Namespace)
+	r.True(msg.Unknown.IsPassthrough())
+
+	relayed, err := json.Marshal(msg)
+	r.Nil(err)
+
+	relayedMap := map[string]string{}
+	r.Nil(json.Unmarshal(relayed, &relayedMap))
+
+	r.Equal(unknownStr, relayedMap["unknown"])
+	r.Equal(unknownStr, msg.Unknown.String())
+
+	gs, err := unknown.Unghostify(relayedMap["unknown"])
+	r.Nil(err)
+	r.Equal("for your eyes only", gs.Str)
+
+	msg.Unknown.Namespace = "test.relay.known"
+	r.False(msg.Unknown.IsPassthrough())
+	r.Equal("", msg.Unknown.String())
+}
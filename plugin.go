@@ -0,0 +1,337 @@
+package ghoststring
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultPluginTimeout is the default time allowed for a plugin
+	// to respond to each request. See WithPluginTimeout.
+	DefaultPluginTimeout = 10 * time.Second
+
+	// PluginErrorExpired, PluginErrorReplayed, and
+	// PluginErrorAudience are the kinds of error a plugin may
+	// respond with that are returned as errors matching ErrExpired,
+	// ErrReplayed, and ErrAudience respectively. Any other kind is
+	// returned as an error matching Err.
+	PluginErrorExpired  = "expired"
+	PluginErrorReplayed = "replayed"
+	PluginErrorAudience = "audience"
+
+	pluginOpGhostify   = "ghostify"
+	pluginOpUnghostify = "unghostify"
+
+	pluginMaxLineSize = 16 * 1024 * 1024
+)
+
+// PluginOption configures optional behavior of the Ghostifyer
+// created via NewPluginGhostifyer.
+type PluginOption func(*pluginOptions)
+
+type pluginOptions struct {
+	timeout time.Duration
+	env     []string
+}
+
+// WithPluginTimeout sets the time allowed for the plugin to respond
+// to each request, after which the plugin process is killed and an
+// error is returned. The default is DefaultPluginTimeout.
+func WithPluginTimeout(timeout time.Duration) PluginOption {
+	return func(o *pluginOptions) {
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
+}
+
+// WithPluginEnv sets the environment of the plugin process. The
+// default is the environment of the current process.
+func WithPluginEnv(env []string) PluginOption {
+	return func(o *pluginOptions) {
+		o.env = env
+	}
+}
+
+// pluginGhostifyer ghostifies and unghostifies via an external
+// process, which is started on first use and reused until it exits,
+// fails to respond in time, or the Ghostifyer is closed.
+type pluginGhostifyer struct {
+	namespace string
+	command   []string
+	opts      *pluginOptions
+
+	lock   sync.Mutex
+	proc   *pluginProcess
+	nextID uint64
+}
+
+type pluginProcess struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan []byte
+}
+
+type pluginRequest struct {
+	ID uint64 `json:"id"`
+	Op string `json:"op"`
+
+	GhostStringFields
+
+	GhostString string `json:"ghoststring,omitempty"`
+}
+
+type pluginResponse struct {
+	ID uint64 `json:"id"`
+
+	GhostStringFields
+
+	GhostString string       `json:"ghoststring,omitempty"`
+	Error       *pluginError `json:"error,omitempty"`
+}
+
+type pluginError struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// NewPluginGhostifyer creates a Ghostifyer for the namespace that
+// runs the command, the first element of which is the executable,
+// and exchanges JSON with it, one object per line, similar to git
+// credential helpers. Each request written to the plugin's stdin is
+// answered in order on its stdout:
+//
+//	{"id":1,"op":"ghostify","namespace":"...","str":"...","max_age_ms":0,"issued_at_ms":0,"token_id":"","header":{}}
+//	{"id":1,"ghoststring":"..."}
+//
+//	{"id":2,"op":"unghostify","namespace":"...","ghoststring":"..."}
+//	{"id":2,"namespace":"...","str":"...","max_age_ms":0,"issued_at_ms":0,"token_id":"","header":{}}
+//
+// or with an error such as:
+//
+//	{"id":2,"error":{"kind":"expired","message":"..."}}
+//
+// where the optional fields are omitted when empty. An unghostified
+// value in any namespace other than the Ghostifyer's is considered
+// an error. The returned Ghostifyer also implements io.Closer to
+// stop the plugin process.
+func NewPluginGhostifyer(namespace string, command []string, opts ...PluginOption) (Ghostifyer, error) {
+	if err := validateNamespace(namespace); err != nil {
+		return nil, err
+	}
+
+	if len(command) == 0 {
+		return nil, errors.Wrap(Err, "no plugin command")
+	}
+
+	o := &pluginOptions{timeout: DefaultPluginTimeout}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &pluginGhostifyer{namespace: namespace, command: command, opts: o}, nil
+}
+
+func (g *pluginGhostifyer) Namespace() string {
+	return g.namespace
+}
+
+func (g *pluginGhostifyer) Ghostify(gs *GhostString) (string, error) {
+	return g.GhostifyWithHeader(gs, nil)
+}
+
+func (g *pluginGhostifyer) GhostifyWithHeader(gs *GhostString, header Header) (string, error) {
+	if gs == nil {
+		return "", errors.Wrap(Err, "nil GhostString")
+	}
+
+	if !gs.IsValid() {
+		return "", nil
+	}
+
+	resp, err := g.call(&pluginRequest{Op: pluginOpGhostify, GhostStringFields: NewGhostStringFields(gs, header)})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.GhostString, nil
+}
+
+func (g *pluginGhostifyer) Unghostify(s string) (*GhostString, error) {
+	un, err := g.UnghostifyDetailed(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return un.GhostString, nil
+}
+
+func (g *pluginGhostifyer) UnghostifyDetailed(s string) (*Unghostified, error) {
+	if isEmptyGhostified(s) {
+		return &Unghostified{GhostString: &GhostString{}, Header: Header{}}, nil
+	}
+
+	resp, err := g.call(
+		&pluginRequest{
+			Op:                pluginOpUnghostify,
+			GhostStringFields: GhostStringFields{Namespace: g.namespace},
+			GhostString:       s,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Namespace != g.namespace {
+		return nil, errors.Wrapf(Err, "plugin responded with namespace %[1]q instead of %[2]q", resp.Namespace, g.namespace)
+	}
+
+	return &Unghostified{GhostString: resp.GhostStringFields.GhostString(), Header: resp.Header}, nil
+}
+
+// Close stops the plugin process, if running.
+func (g *pluginGhostifyer) Close() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.stop()
+
+	return nil
+}
+
+func (g *pluginGhostifyer) call(req *pluginRequest) (*pluginResponse, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.proc == nil {
+		if err := g.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	g.nextID++
+	req.ID = g.nextID
+
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := g.proc.stdin.Write(append(reqBytes, '\n')); err != nil {
+		g.stop()
+
+		return nil, errors.Wrapf(Err, "plugin unavailable: %[1]v", err)
+	}
+
+	timer := time.NewTimer(g.opts.timeout)
+	defer timer.Stop()
+
+	var line []byte
+
+	select {
+	case l, ok := <-g.proc.responses:
+		if !ok {
+			g.stop()
+
+			return nil, errors.Wrap(Err, "plugin exited")
+		}
+
+		line = l
+	case <-timer.C:
+		g.stop()
+
+		return nil, errors.Wrapf(Err, "plugin timed out after %[1]v", g.opts.timeout)
+	}
+
+	resp := &pluginResponse{}
+	if err := json.Unmarshal(line, resp); err != nil || resp.ID != req.ID {
+		g.stop()
+
+		return nil, errors.Wrap(Err, "invalid plugin response")
+	}
+
+	if resp.Error != nil {
+		return nil, toPluginError(resp.Error)
+	}
+
+	return resp, nil
+}
+
+func (g *pluginGhostifyer) start() error {
+	cmd := exec.Command(g.command[0], g.command[1:]...)
+	cmd.Env = g.opts.env
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return errors.Wrapf(Err, "plugin failed to start: %[1]v", err)
+	}
+
+	responses := make(chan []byte, 1)
+
+	go func() {
+		defer close(responses)
+
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), pluginMaxLineSize)
+
+		for scanner.Scan() {
+			responses <- append([]byte{}, scanner.Bytes()...)
+		}
+	}()
+
+	g.proc = &pluginProcess{cmd: cmd, stdin: stdin, responses: responses}
+
+	return nil
+}
+
+// stop kills the plugin process, if running, so that the next
+// request starts a new one rather than reading a late response.
+func (g *pluginGhostifyer) stop() {
+	if g.proc == nil {
+		return
+	}
+
+	proc := g.proc
+	g.proc = nil
+
+	_ = proc.stdin.Close()
+	_ = proc.cmd.Process.Kill()
+
+	go func() {
+		for range proc.responses {
+		}
+
+		_ = proc.cmd.Wait()
+	}()
+}
+
+func toPluginError(pe *pluginError) error {
+	switch pe.Kind {
+	case PluginErrorExpired:
+		return errors.Wrap(ErrExpired, pe.Message)
+	case PluginErrorReplayed:
+		return errors.Wrap(ErrReplayed, pe.Message)
+	case PluginErrorAudience:
+		return errors.Wrap(ErrAudience, pe.Message)
+	}
+
+	return errors.Wrapf(Err, "plugin error %[1]q: %[2]v", pe.Kind, pe.Message)
+}
@@ -0,0 +1,113 @@
+package ghoststring_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginGhostifyer(t *testing.T) {
+	gs := &ghoststring.GhostString{Namespace: "test.plugin", Str: "off the books"}
+
+	for _, tc := range []struct {
+		name   string
+		script string
+		target error
+	}{
+		{name: "exited", script: "exit 0", target: ghoststring.Err},
+		{name: "timed out", script: "sleep 10", target: ghoststring.Err},
+		{name: "invalid response", script: "read l; echo garbage; sleep 10", target: ghoststring.Err},
+		{
+			name:   "expired",
+			script: `read l; echo '{"id":1,"error":{"kind":"expired","message":"too late"}}'; sleep 10`,
+			target: ghoststring.ErrExpired,
+		},
+		{
+			name:   "replayed",
+			script: `read l; echo '{"id":1,"error":{"kind":"replayed","message":"again"}}'; sleep 10`,
+			target: ghoststring.ErrReplayed,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+
+			gh, err := ghoststring.NewPluginGhostifyer(
+				"test.plugin",
+				[]string{"sh", "-c", tc.script},
+				ghoststring.WithPluginTimeout(200*time.Millisecond),
+			)
+			r.Nil(err)
+			defer func() { r.Nil(gh.(io.Closer).Close()) }()
+
+			_, err = gh.Ghostify(gs)
+			r.ErrorIs(err, tc.target)
+		})
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		r := require.New(t)
+
+		requestsPath := filepath.Join(t.TempDir(), "requests")
+
+		script := `read l; echo "$l" >> "$1"; echo '{"id":1,"ghoststring":"👻:b2ZmIHRoZSBib29rcw=="}'
+read l; echo "$l" >> "$1"; echo '{"id":2,"namespace":"test.plugin","str":"off the books","max_age_ms":60000,"token_id":"ledger","header":{"purpose":"testing"}}'
+read l; echo "$l" >> "$1"; echo '{"id":3,"namespace":"test.plugin.other","str":"someone else'"'"'s books"}'
+read l`
+
+		gh, err := ghoststring.NewPluginGhostifyer(
+			"test.plugin",
+			[]string{"sh", "-c", script, "plugin", requestsPath},
+			ghoststring.WithPluginTimeout(time.Second),
+		)
+		r.Nil(err)
+		defer func() { r.Nil(gh.(io.Closer).Close()) }()
+
+		s, err := gh.Ghostify(gs)
+		r.Nil(err)
+		r.Equal("👻:b2ZmIHRoZSBib29rcw==", s)
+
+		un, err := gh.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(s)
+		r.Nil(err)
+		r.Equal("test.plugin", un.GhostString.Namespace)
+		r.Equal("off the books", un.GhostString.Str)
+		r.Equal(time.Minute, un.GhostString.MaxAge)
+		r.Equal("ledger", un.GhostString.TokenID)
+		r.Equal(ghoststring.Header{ghoststring.HeaderPurpose: "testing"}, un.UserHeader())
+
+		_, err = gh.Unghostify(s)
+		r.ErrorIs(err, ghoststring.Err)
+
+		requests, err := os.ReadFile(requestsPath)
+		r.Nil(err)
+		r.Contains(string(requests), `{"id":1,"op":"ghostify","namespace":"test.plugin","str":"off the books"}`)
+		r.Contains(string(requests), `{"id":2,"op":"unghostify","namespace":"test.plugin","str":"","ghoststring":"👻:b2ZmIHRoZSBib29rcw=="}`)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		r := require.New(t)
+
+		_, err := ghoststring.NewPluginGhostifyer("test.plugin", nil)
+		r.ErrorIs(err, ghoststring.Err)
+
+		_, err = ghoststring.NewPluginGhostifyer("!", []string{"true"})
+		r.ErrorIs(err, ghoststring.Err)
+
+		gh, err := ghoststring.NewPluginGhostifyer("test.plugin", []string{"/nonexistent/plugin"})
+		r.Nil(err)
+
+		_, err = gh.Ghostify(gs)
+		r.ErrorIs(err, ghoststring.Err)
+
+		_, err = gh.Ghostify(nil)
+		r.ErrorIs(err, ghoststring.Err)
+
+		unGS, err := gh.Unghostify("")
+		r.Nil(err)
+		r.Equal("", unGS.Str)
+	})
+}
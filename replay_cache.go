@@ -0,0 +1,117 @@
+package ghoststring
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	tokenIDLen = 16
+
+	// replayCacheMinSweepSize is the number of token IDs an
+	// in-memory replay cache holds before expired ones are first
+	// swept out.
+	replayCacheMinSweepSize = 1024
+)
+
+// ReplayCache records the token IDs of one-time GhostStrings that
+// have been unghostified so that subsequent attempts may be
+// rejected.
+type ReplayCache interface {
+	// Add records the token ID as used until expiresAt, returning
+	// an error matching ErrReplayed if it has already been
+	// recorded. A zero expiresAt indicates that the cache should
+	// use its own retention period.
+	Add(ctx context.Context, tokenID string, expiresAt time.Time) error
+}
+
+// NewTokenID generates a random token ID suitable for use as
+// GhostString.TokenID.
+func NewTokenID() (string, error) {
+	b := make([]byte, tokenIDLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewInMemoryReplayCache creates a ReplayCache that retains token
+// IDs in memory until they expire. The ttl is used for values that
+// are not time-limited.
+func NewInMemoryReplayCache(ttl time.Duration) ReplayCache {
+	return &inMemoryReplayCache{
+		ttl:       ttl,
+		now:       time.Now,
+		seen:      map[string]time.Time{},
+		sweepSize: replayCacheMinSweepSize,
+	}
+}
+
+// inMemoryReplayCache sweeps out expired token IDs only once it
+// holds sweepSize of them, after which sweepSize is set to twice the
+// number remaining, so that the cost of sweeping is amortized across
+// calls to Add.
+type inMemoryReplayCache struct {
+	ttl       time.Duration
+	now       func() time.Time
+	seen      map[string]time.Time
+	sweepSize int
+	lock      sync.Mutex
+}
+
+func (rc *inMemoryReplayCache) Add(_ context.Context, tokenID string, expiresAt time.Time) error {
+	now := rc.now()
+
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(rc.ttl)
+	}
+
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	if exp, ok := rc.seen[tokenID]; ok && exp.After(now) {
+		return errors.Wrapf(ErrReplayed, "token %[1]q", tokenID)
+	}
+
+	rc.seen[tokenID] = expiresAt
+
+	if len(rc.seen) >= rc.sweepSize {
+		rc.sweep(now)
+	}
+
+	return nil
+}
+
+func (rc *inMemoryReplayCache) sweep(now time.Time) {
+	for id, exp := range rc.seen {
+		if !exp.After(now) {
+			delete(rc.seen, id)
+		}
+	}
+
+	rc.sweepSize = 2 * len(rc.seen)
+	if rc.sweepSize < replayCacheMinSweepSize {
+		rc.sweepSize = replayCacheMinSweepSize
+	}
+}
+
+// checkReplay records the token ID of a one-time GhostString with
+// the replay cache, if any.
+func checkReplay(gs *GhostString, opts *ghostifyerOptions) error {
+	if gs.TokenID == "" || opts.replayCache == nil {
+		return nil
+	}
+
+	expiresAt := time.Time{}
+	if gs.MaxAge > 0 {
+		expiresAt = gs.IssuedAt.Add(gs.MaxAge + opts.clockSkew)
+	}
+
+	return opts.replayCache.Add(context.TODO(), gs.TokenID, expiresAt)
+}
@@ -0,0 +1,83 @@
+package ghoststring
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryReplayCache(t *testing.T) {
+	r := require.New(t)
+
+	now := time.UnixMilli(1661351759000)
+
+	rc := NewInMemoryReplayCache(time.Minute).(*inMemoryReplayCache)
+	rc.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	r.Nil(rc.Add(ctx, "a", time.Time{}))
+	r.ErrorIs(rc.Add(ctx, "a", time.Time{}), ErrReplayed)
+	r.ErrorIs(rc.Add(ctx, "a", time.Time{}), Err)
+
+	r.Nil(rc.Add(ctx, "b", now.Add(time.Hour)))
+
+	now = now.Add(2 * time.Minute)
+
+	r.Nil(rc.Add(ctx, "a", time.Time{}))
+	r.ErrorIs(rc.Add(ctx, "b", time.Time{}), ErrReplayed)
+	r.Len(rc.seen, 2)
+
+	for i := 0; len(rc.seen) < replayCacheMinSweepSize-1; i++ {
+		r.Nil(rc.Add(ctx, fmt.Sprintf("c%d", i), now.Add(time.Duration(i%2)*time.Hour)))
+	}
+
+	r.Equal(replayCacheMinSweepSize, rc.sweepSize)
+
+	now = now.Add(time.Minute)
+
+	r.Nil(rc.Add(ctx, "d", time.Time{}))
+	r.Less(len(rc.seen), replayCacheMinSweepSize/2+2)
+	r.Equal(replayCacheMinSweepSize, rc.sweepSize)
+	r.ErrorIs(rc.Add(ctx, "b", time.Time{}), ErrReplayed)
+	r.ErrorIs(rc.Add(ctx, "d", time.Time{}), ErrReplayed)
+}
+
+func TestGhostString_OneTime(t *testing.T) {
+	r := require.New(t)
+
+	gh, err := NewAES256GCMSingleKeyGhostifyer(
+		"test.once",
+		"only the lonely",
+		WithReplayCache(NewInMemoryReplayCache(time.Hour)),
+	)
+	r.Nil(err)
+
+	tokenID, err := NewTokenID()
+	r.Nil(err)
+	r.NotEqual("", tokenID)
+
+	once, err := gh.Ghostify(&GhostString{Namespace: "test.once", Str: "state", TokenID: tokenID})
+	r.Nil(err)
+
+	many, err := gh.Ghostify(&GhostString{Namespace: "test.once", Str: "state"})
+	r.Nil(err)
+
+	gs, err := gh.Unghostify(once)
+	r.Nil(err)
+	r.Equal("state", gs.Str)
+	r.Equal(tokenID, gs.TokenID)
+
+	gs, err = gh.Unghostify(once)
+	r.ErrorIs(err, ErrReplayed)
+	r.Nil(gs)
+
+	for i := 0; i < 2; i++ {
+		gs, err = gh.Unghostify(many)
+		r.Nil(err)
+		r.Equal("state", gs.Str)
+	}
+}
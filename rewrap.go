@@ -0,0 +1,48 @@
+package ghoststring
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	_ Rewrapper = &aes256GcmSingleKeyGhostifyer{}
+	_ Rewrapper = &aes256GcmMultiKeyGhostifyer{}
+)
+
+// Rewrapper is implemented by Ghostifyers that are able to rewrap
+// values ghostified with an older key so that the older key may be
+// retired after rotation.
+type Rewrapper interface {
+	// Rewrap ghostifies the value again with the latest key if it
+	// was ghostified with any other, reporting whether it changed.
+	// Unchanged values are returned as given.
+	Rewrap(string) (string, bool, error)
+}
+
+// Rewrap rewraps the value with the Ghostifyer registered for its
+// namespace, reporting whether it changed. The issue time, max age,
+// token ID, header, and Encoding of the value are retained, and
+// values that have expired or been used are rewrapped without
+// error, since rewrapping changes neither.
+func Rewrap(s string) (string, bool, error) {
+	if isEmptyGhostified(s) {
+		return s, false, nil
+	}
+
+	namespace, err := PeekNamespace(s)
+	if err != nil {
+		return "", false, err
+	}
+
+	ghostifyer, ok := getGhostifyer(namespace)
+	if !ok {
+		return "", false, errors.Wrapf(Err, "no ghostifyer set for namespace %[1]q", namespace)
+	}
+
+	rw, ok := ghostifyer.(Rewrapper)
+	if !ok {
+		return "", false, errors.Wrapf(Err, "ghostifyer for namespace %[1]q does not rewrap", namespace)
+	}
+
+	return rw.Rewrap(s)
+}
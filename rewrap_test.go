@@ -0,0 +1,163 @@
+package ghoststring_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrap(t *testing.T) {
+	r := require.New(t)
+
+	oldKey := &ghoststring.TimestampedKey{Timestamp: 1661351759000, Key: "worn out"}
+	newKey := &ghoststring.TimestampedKey{Timestamp: 1692887759000, Key: "fresh out of the box"}
+
+	oldKS, err := ghoststring.NewKeyStore("test.rewrap", []*ghoststring.TimestampedKey{oldKey})
+	r.Nil(err)
+
+	newKS, err := ghoststring.NewKeyStore("test.rewrap", []*ghoststring.TimestampedKey{newKey})
+	r.Nil(err)
+
+	rotatedKS, err := ghoststring.NewKeyStore("test.rewrap", []*ghoststring.TimestampedKey{oldKey, newKey})
+	r.Nil(err)
+
+	oldGh := ghoststring.NewAES256GCMMultiKeyGhostifyer("test.rewrap", oldKS)
+	newGh := ghoststring.NewAES256GCMMultiKeyGhostifyer("test.rewrap", newKS)
+	rotatedGh := ghoststring.NewAES256GCMMultiKeyGhostifyer(
+		"test.rewrap",
+		rotatedKS,
+		ghoststring.WithReplayCache(ghoststring.NewInMemoryReplayCache(time.Hour)),
+	)
+
+	r.Nil(ghoststring.SetGhostifyer(rotatedGh))
+
+	issuedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+
+	for _, tc := range []struct {
+		name   string
+		gs     *ghoststring.GhostString
+		header ghoststring.Header
+		opts   []ghoststring.GhostifyerOption
+	}{
+		{
+			name: "plain",
+			gs:   &ghoststring.GhostString{Namespace: "test.rewrap", Str: "hand me down"},
+		},
+		{
+			name: "expired",
+			gs: &ghoststring.GhostString{
+				Namespace: "test.rewrap",
+				Str:       "past its prime",
+				MaxAge:    time.Minute,
+				IssuedAt:  issuedAt,
+				TokenID:   "once-upon-a-time",
+			},
+			header: ghoststring.Header{ghoststring.HeaderPurpose: "archive"},
+		},
+		{
+			name: "ascii",
+			gs:   &ghoststring.GhostString{Namespace: "test.rewrap", Str: "ok"},
+			opts: []ghoststring.GhostifyerOption{ghoststring.WithEncoding(ghoststring.EncodingASCII)},
+		},
+		{
+			name: "url-safe",
+			gs:   &ghoststring.GhostString{Namespace: "test.rewrap", Str: strings.Repeat("?", 100)},
+			opts: []ghoststring.GhostifyerOption{ghoststring.WithEncoding(ghoststring.EncodingURL)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+
+			gh := ghoststring.NewAES256GCMMultiKeyGhostifyer("test.rewrap", oldKS, tc.opts...)
+
+			s, err := gh.(ghoststring.HeaderGhostifyer).GhostifyWithHeader(tc.gs, tc.header)
+			r.Nil(err)
+
+			rewrapped, changed, err := ghoststring.Rewrap(s)
+			r.Nil(err)
+			r.True(changed)
+			r.NotEqual(s, rewrapped)
+			r.Equal(s[:strings.Index(s, ":")], rewrapped[:strings.Index(rewrapped, ":")])
+
+			_, err = oldGh.Unghostify(rewrapped)
+			r.Error(err)
+
+			un, err := newGh.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(rewrapped)
+			if tc.gs.MaxAge > 0 {
+				r.ErrorIs(err, ghoststring.ErrExpired)
+
+				un, err = ghoststring.NewAES256GCMMultiKeyGhostifyer(
+					"test.rewrap",
+					newKS,
+					ghoststring.WithClock(func() time.Time { return issuedAt }),
+				).(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(rewrapped)
+			}
+
+			r.Nil(err)
+			r.Equal(tc.gs.Str, un.GhostString.Str)
+			r.Equal(tc.gs.MaxAge, un.GhostString.MaxAge)
+			r.Equal(tc.gs.TokenID, un.GhostString.TokenID)
+			r.Equal(tc.header, un.UserHeader())
+
+			if tc.gs.MaxAge > 0 {
+				r.True(issuedAt.Equal(un.GhostString.IssuedAt))
+			} else {
+				r.NotContains(un.Header, ghoststring.HeaderIssuedAt)
+			}
+
+			again, changed, err := ghoststring.Rewrap(rewrapped)
+			r.Nil(err)
+			r.False(changed)
+			r.Equal(rewrapped, again)
+		})
+	}
+
+	t.Run("single key", func(t *testing.T) {
+		r := require.New(t)
+
+		gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.rewrap.single", "only one")
+		r.Nil(err)
+
+		s, err := gh.Ghostify(&ghoststring.GhostString{Namespace: "test.rewrap.single", Str: "as is"})
+		r.Nil(err)
+
+		rewrapped, changed, err := gh.(ghoststring.Rewrapper).Rewrap(s)
+		r.Nil(err)
+		r.False(changed)
+		r.Equal(s, rewrapped)
+
+		s, err = oldGh.Ghostify(&ghoststring.GhostString{Namespace: "test.rewrap", Str: "not mine"})
+		r.Nil(err)
+
+		_, _, err = gh.(ghoststring.Rewrapper).Rewrap(s)
+		r.Error(err)
+	})
+
+	t.Run("registry", func(t *testing.T) {
+		r := require.New(t)
+
+		rewrapped, changed, err := ghoststring.Rewrap("")
+		r.Nil(err)
+		r.False(changed)
+		r.Equal("", rewrapped)
+
+		unregistered, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.rewrap.unregistered", "nobody")
+		r.Nil(err)
+
+		s, err := unregistered.Ghostify(&ghoststring.GhostString{Namespace: "test.rewrap.unregistered", Str: "lost"})
+		r.Nil(err)
+
+		_, _, err = ghoststring.Rewrap(s)
+		r.ErrorIs(err, ghoststring.Err)
+
+		plugin, err := ghoststring.NewPluginGhostifyer("test.rewrap.unregistered", []string{"true"})
+		r.Nil(err)
+		r.Nil(ghoststring.SetGhostifyer(plugin))
+
+		_, _, err = ghoststring.Rewrap(s)
+		r.ErrorIs(err, ghoststring.Err)
+	})
+}
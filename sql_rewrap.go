@@ -0,0 +1,483 @@
+package ghoststring
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultSQLRewrapBatchSize is the default number of rows read
+	// at a time by RewrapSQL. See WithSQLBatchSize.
+	DefaultSQLRewrapBatchSize = 1000
+
+	sqlIdentifierRegexp = "^[a-zA-Z_][a-zA-Z0-9_]*(\\.[a-zA-Z_][a-zA-Z0-9_]*)?$"
+)
+
+var (
+	sqlIdentifierMatch = regexp.MustCompile(sqlIdentifierRegexp)
+)
+
+// SQLRewrapOption configures optional behavior of RewrapSQL.
+type SQLRewrapOption func(*sqlRewrapOptions)
+
+type sqlRewrapOptions struct {
+	batchSize   int
+	concurrency int
+	dryRun      bool
+	skipErrors  bool
+	dollar      bool
+	checkpoint  SQLCheckpoint
+	progress    func(*SQLRewrapProgress)
+}
+
+// WithSQLBatchSize sets the number of rows read at a time, after
+// each of which the checkpoint is saved and progress reported. The
+// default is DefaultSQLRewrapBatchSize.
+func WithSQLBatchSize(batchSize int) SQLRewrapOption {
+	return func(o *sqlRewrapOptions) {
+		if batchSize > 0 {
+			o.batchSize = batchSize
+		}
+	}
+}
+
+// WithSQLConcurrency sets the number of rows in each batch that are
+// rewrapped and updated at once. The default is 1.
+func WithSQLConcurrency(concurrency int) SQLRewrapOption {
+	return func(o *sqlRewrapOptions) {
+		if concurrency > 0 {
+			o.concurrency = concurrency
+		}
+	}
+}
+
+// WithSQLDryRun counts the values that would be rewrapped without
+// updating any rows or saving the checkpoint.
+func WithSQLDryRun() SQLRewrapOption {
+	return func(o *sqlRewrapOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithSQLSkipErrors counts values that fail to rewrap, such as those
+// in a namespace without a registered Ghostifyer, as Failed rather
+// than stopping.
+func WithSQLSkipErrors() SQLRewrapOption {
+	return func(o *sqlRewrapOptions) {
+		o.skipErrors = true
+	}
+}
+
+// WithSQLDollarPlaceholders uses numbered placeholders such as $1,
+// as required by PostgreSQL drivers, rather than ?.
+func WithSQLDollarPlaceholders() SQLRewrapOption {
+	return func(o *sqlRewrapOptions) {
+		o.dollar = true
+	}
+}
+
+// WithSQLCheckpoint resumes after the primary key loaded from the
+// checkpoint, which is saved after each batch.
+func WithSQLCheckpoint(checkpoint SQLCheckpoint) SQLRewrapOption {
+	return func(o *sqlRewrapOptions) {
+		o.checkpoint = checkpoint
+	}
+}
+
+// WithSQLProgress sets a function that is called with the progress
+// so far after each batch.
+func WithSQLProgress(progress func(*SQLRewrapProgress)) SQLRewrapOption {
+	return func(o *sqlRewrapOptions) {
+		o.progress = progress
+	}
+}
+
+// SQLCheckpoint persists the primary key of the last row of the
+// last batch completed by RewrapSQL so that it may resume after an
+// interruption.
+type SQLCheckpoint interface {
+	// Load returns the saved primary key, or "" if none.
+	Load(ctx context.Context) (string, error)
+	// Save records the primary key.
+	Save(ctx context.Context, primaryKey string) error
+}
+
+// NewFileSQLCheckpoint creates an SQLCheckpoint that saves the
+// primary key to the file at path, which need not exist.
+func NewFileSQLCheckpoint(path string) SQLCheckpoint {
+	return &fileSQLCheckpoint{path: path}
+}
+
+type fileSQLCheckpoint struct {
+	path string
+}
+
+func (cp *fileSQLCheckpoint) Load(context.Context) (string, error) {
+	b, err := os.ReadFile(cp.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Save writes the primary key to a temporary file that is renamed
+// into place so that an interruption does not leave it truncated.
+func (cp *fileSQLCheckpoint) Save(_ context.Context, primaryKey string) error {
+	f, err := os.CreateTemp(filepath.Dir(cp.path), filepath.Base(cp.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.WriteString(primaryKey + "\n"); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), cp.path)
+}
+
+// SQLRewrapProgress counts the work done by RewrapSQL.
+type SQLRewrapProgress struct {
+	// Checkpoint is the primary key of the last row of the last
+	// batch completed.
+	Checkpoint string
+
+	// Rows is the number of rows read.
+	Rows int64
+	// Values is the number of ghostified values read, excluding
+	// NULL, empty, and plain text values.
+	Values int64
+	// Rewrapped is the number of values rewrapped, or that would
+	// have been in a dry run.
+	Rewrapped int64
+	// Conflicts is the number of rows that were not updated because
+	// they changed while being rewrapped.
+	Conflicts int64
+	// Failed is the number of values that failed to rewrap. See
+	// WithSQLSkipErrors.
+	Failed int64
+}
+
+type sqlRewrapper struct {
+	db         *sql.DB
+	table      string
+	primaryKey string
+	columns    []string
+	opts       *sqlRewrapOptions
+
+	lock     sync.Mutex
+	progress SQLRewrapProgress
+}
+
+// sqlRewrapRow is a row as read, the primary key of which is kept
+// as scanned so that it is passed back to the driver unchanged.
+type sqlRewrapRow struct {
+	primaryKey any
+	values     []sql.NullString
+}
+
+// RewrapSQL rewraps the ghostified values in the columns of every
+// row of the table with the Ghostifyer registered for the namespace
+// of each value, as with Rewrap. Rows are read in batches ordered by
+// the primary key, and each row with a changed value is updated
+// only if its values are unchanged since being read. The table,
+// primary key, and column names are not quoted and must be plain
+// identifiers.
+func RewrapSQL(ctx context.Context, db *sql.DB, table, primaryKey string, columns []string, opts ...SQLRewrapOption) (*SQLRewrapProgress, error) {
+	for _, identifier := range append([]string{table, primaryKey}, columns...) {
+		if !sqlIdentifierMatch.MatchString(identifier) {
+			return nil, errors.Wrapf(Err, "invalid SQL identifier %[1]q", identifier)
+		}
+	}
+
+	if len(columns) == 0 {
+		return nil, errors.Wrap(Err, "no columns to rewrap")
+	}
+
+	o := &sqlRewrapOptions{batchSize: DefaultSQLRewrapBatchSize, concurrency: 1}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	rw := &sqlRewrapper{
+		db:         db,
+		table:      table,
+		primaryKey: primaryKey,
+		columns:    columns,
+		opts:       o,
+	}
+
+	if err := rw.run(ctx); err != nil {
+		return rw.snapshot(), err
+	}
+
+	return rw.snapshot(), nil
+}
+
+func (rw *sqlRewrapper) run(ctx context.Context) error {
+	var after any
+
+	if rw.opts.checkpoint != nil {
+		checkpoint, err := rw.opts.checkpoint.Load(ctx)
+		if err != nil {
+			return err
+		}
+
+		rw.progress.Checkpoint = checkpoint
+
+		if checkpoint != "" {
+			after = checkpoint
+		}
+	}
+
+	for {
+		batch, err := rw.readBatch(ctx, after)
+		if err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := rw.rewrapBatch(ctx, batch); err != nil {
+			return err
+		}
+
+		rw.lock.Lock()
+		rw.progress.Rows += int64(len(batch))
+		after = batch[len(batch)-1].primaryKey
+		rw.progress.Checkpoint = sqlPrimaryKeyString(after)
+		rw.lock.Unlock()
+
+		if rw.opts.checkpoint != nil && !rw.opts.dryRun {
+			if err := rw.opts.checkpoint.Save(ctx, rw.progress.Checkpoint); err != nil {
+				return err
+			}
+		}
+
+		if rw.opts.progress != nil {
+			rw.opts.progress(rw.snapshot())
+		}
+
+		if len(batch) < rw.opts.batchSize {
+			return nil
+		}
+	}
+}
+
+func (rw *sqlRewrapper) readBatch(ctx context.Context, after any) ([]*sqlRewrapRow, error) {
+	query := fmt.Sprintf("SELECT %[1]s, %[2]s FROM %[3]s", rw.primaryKey, strings.Join(rw.columns, ", "), rw.table)
+	args := []any{}
+
+	if after != nil {
+		query += fmt.Sprintf(" WHERE %[1]s > %[2]s", rw.primaryKey, rw.placeholder(1))
+		args = append(args, after)
+	}
+
+	query += fmt.Sprintf(" ORDER BY %[1]s LIMIT %[2]d", rw.primaryKey, rw.opts.batchSize)
+
+	rows, err := rw.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	batch := []*sqlRewrapRow{}
+
+	for rows.Next() {
+		row := &sqlRewrapRow{values: make([]sql.NullString, len(rw.columns))}
+
+		dest := []any{&row.primaryKey}
+		for i := range row.values {
+			dest = append(dest, &row.values[i])
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		batch = append(batch, row)
+	}
+
+	return batch, rows.Err()
+}
+
+func (rw *sqlRewrapper) rewrapBatch(ctx context.Context, batch []*sqlRewrapRow) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rowCh := make(chan *sqlRewrapRow)
+	errCh := make(chan error, rw.opts.concurrency)
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < rw.opts.concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for row := range rowCh {
+				if err := rw.rewrapRow(ctx, row); err != nil {
+					errCh <- err
+					cancel()
+
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, row := range batch {
+		select {
+		case rowCh <- row:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(rowCh)
+	wg.Wait()
+	close(errCh)
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+func (rw *sqlRewrapper) rewrapRow(ctx context.Context, row *sqlRewrapRow) error {
+	setColumns := []string{}
+	setArgs := []any{}
+	whereArgs := []any{}
+
+	for i, value := range row.values {
+		if !value.Valid || isEmptyGhostified(value.String) || !hasGhostifiedPrefix(value.String) {
+			continue
+		}
+
+		rw.count(func(p *SQLRewrapProgress) { p.Values++ })
+
+		rewrapped, changed, err := Rewrap(value.String)
+		if err != nil {
+			if !rw.opts.skipErrors {
+				return errors.Wrapf(err, "%[1]s %[2]q column %[3]s", rw.primaryKey, sqlPrimaryKeyString(row.primaryKey), rw.columns[i])
+			}
+
+			rw.count(func(p *SQLRewrapProgress) { p.Failed++ })
+
+			continue
+		}
+
+		if !changed {
+			continue
+		}
+
+		setColumns = append(setColumns, rw.columns[i])
+		setArgs = append(setArgs, rewrapped)
+		whereArgs = append(whereArgs, value.String)
+	}
+
+	if len(setColumns) == 0 {
+		return nil
+	}
+
+	if rw.opts.dryRun {
+		rw.count(func(p *SQLRewrapProgress) { p.Rewrapped += int64(len(setColumns)) })
+
+		return nil
+	}
+
+	sets := []string{}
+	wheres := []string{fmt.Sprintf("%[1]s = %[2]s", rw.primaryKey, rw.placeholder(len(setColumns)+1))}
+
+	for i, column := range setColumns {
+		sets = append(sets, fmt.Sprintf("%[1]s = %[2]s", column, rw.placeholder(i+1)))
+		wheres = append(wheres, fmt.Sprintf("%[1]s = %[2]s", column, rw.placeholder(len(setColumns)+i+2)))
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %[1]s SET %[2]s WHERE %[3]s",
+		rw.table, strings.Join(sets, ", "), strings.Join(wheres, " AND "),
+	)
+
+	args := append(append(setArgs, row.primaryKey), whereArgs...)
+
+	result, err := rw.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		rw.count(func(p *SQLRewrapProgress) { p.Conflicts++ })
+
+		return nil
+	}
+
+	rw.count(func(p *SQLRewrapProgress) { p.Rewrapped += int64(len(setColumns)) })
+
+	return nil
+}
+
+func (rw *sqlRewrapper) placeholder(n int) string {
+	if rw.opts.dollar {
+		return fmt.Sprintf("$%[1]d", n)
+	}
+
+	return "?"
+}
+
+func (rw *sqlRewrapper) count(f func(*SQLRewrapProgress)) {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+
+	f(&rw.progress)
+}
+
+func (rw *sqlRewrapper) snapshot() *SQLRewrapProgress {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+
+	progress := rw.progress
+
+	return &progress
+}
+
+// sqlPrimaryKeyString formats a scanned primary key for use as a
+// checkpoint.
+func sqlPrimaryKeyString(primaryKey any) string {
+	if b, ok := primaryKey.([]byte); ok {
+		return string(b)
+	}
+
+	return fmt.Sprint(primaryKey)
+}
@@ -0,0 +1,235 @@
+package ghoststring_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestRewrapSQL(t *testing.T) {
+	r := require.New(t)
+
+	oldKey := &ghoststring.TimestampedKey{Timestamp: 1661351759000, Key: "dusty ledger"}
+	newKey := &ghoststring.TimestampedKey{Timestamp: 1692887759000, Key: "clean slate"}
+
+	oldKS, err := ghoststring.NewKeyStore("test.sqlrewrap", []*ghoststring.TimestampedKey{oldKey})
+	r.Nil(err)
+
+	newKS, err := ghoststring.NewKeyStore("test.sqlrewrap", []*ghoststring.TimestampedKey{newKey})
+	r.Nil(err)
+
+	rotatedKS, err := ghoststring.NewKeyStore("test.sqlrewrap", []*ghoststring.TimestampedKey{oldKey, newKey})
+	r.Nil(err)
+
+	oldGh := ghoststring.NewAES256GCMMultiKeyGhostifyer("test.sqlrewrap", oldKS)
+	newGh := ghoststring.NewAES256GCMMultiKeyGhostifyer("test.sqlrewrap", newKS)
+
+	r.Nil(ghoststring.SetGhostifyer(ghoststring.NewAES256GCMMultiKeyGhostifyer("test.sqlrewrap", rotatedKS)))
+
+	ctx := context.Background()
+
+	// newDB creates a table of 25 rows, each with a token under the
+	// old key and a note that is NULL, plain text, or under the new
+	// key.
+	newDB := func(r *require.Assertions) *sql.DB {
+		db, err := sql.Open("sqlite", ":memory:")
+		r.Nil(err)
+
+		db.SetMaxOpenConns(1)
+
+		_, err = db.ExecContext(ctx, "CREATE TABLE accounts (id INTEGER PRIMARY KEY, token TEXT, note TEXT)")
+		r.Nil(err)
+
+		for i := 1; i <= 25; i++ {
+			token, err := oldGh.Ghostify(&ghoststring.GhostString{Namespace: "test.sqlrewrap", Str: fmt.Sprintf("token %d", i)})
+			r.Nil(err)
+
+			var note any
+
+			switch i % 3 {
+			case 1:
+				note = fmt.Sprintf("plain %d", i)
+			case 2:
+				note, err = newGh.Ghostify(&ghoststring.GhostString{Namespace: "test.sqlrewrap", Str: fmt.Sprintf("note %d", i)})
+				r.Nil(err)
+			}
+
+			_, err = db.ExecContext(ctx, "INSERT INTO accounts (id, token, note) VALUES (?, ?, ?)", i, token, note)
+			r.Nil(err)
+		}
+
+		return db
+	}
+
+	readTokens := func(r *require.Assertions, db *sql.DB) []string {
+		rows, err := db.QueryContext(ctx, "SELECT token FROM accounts ORDER BY id")
+		r.Nil(err)
+		defer rows.Close()
+
+		tokens := []string{}
+
+		for rows.Next() {
+			var token string
+			r.Nil(rows.Scan(&token))
+
+			tokens = append(tokens, token)
+		}
+
+		r.Nil(rows.Err())
+
+		return tokens
+	}
+
+	t.Run("rewrap", func(t *testing.T) {
+		r := require.New(t)
+
+		db := newDB(r)
+		defer db.Close()
+
+		before := readTokens(r, db)
+		checkpointPath := filepath.Join(t.TempDir(), "checkpoint")
+
+		progress, err := ghoststring.RewrapSQL(
+			ctx, db, "accounts", "id", []string{"token", "note"},
+			ghoststring.WithSQLBatchSize(10),
+			ghoststring.WithSQLCheckpoint(ghoststring.NewFileSQLCheckpoint(checkpointPath)),
+			ghoststring.WithSQLDryRun(),
+		)
+		r.Nil(err)
+		r.Equal(int64(25), progress.Rows)
+		r.Equal(int64(33), progress.Values)
+		r.Equal(int64(25), progress.Rewrapped)
+		r.Equal(before, readTokens(r, db))
+		r.NoFileExists(checkpointPath)
+
+		reports := []*ghoststring.SQLRewrapProgress{}
+
+		progress, err = ghoststring.RewrapSQL(
+			ctx, db, "accounts", "id", []string{"token", "note"},
+			ghoststring.WithSQLBatchSize(10),
+			ghoststring.WithSQLConcurrency(4),
+			ghoststring.WithSQLCheckpoint(ghoststring.NewFileSQLCheckpoint(checkpointPath)),
+			ghoststring.WithSQLProgress(func(p *ghoststring.SQLRewrapProgress) {
+				reports = append(reports, p)
+			}),
+		)
+		r.Nil(err)
+		r.Equal(int64(25), progress.Rows)
+		r.Equal(int64(25), progress.Rewrapped)
+		r.Equal(int64(0), progress.Conflicts)
+		r.Equal("25", progress.Checkpoint)
+
+		r.Len(reports, 3)
+		r.Equal("10", reports[0].Checkpoint)
+		r.Equal(int64(20), reports[1].Rows)
+
+		checkpointBytes, err := os.ReadFile(checkpointPath)
+		r.Nil(err)
+		r.Equal("25\n", string(checkpointBytes))
+
+		for i, token := range readTokens(r, db) {
+			gs, err := newGh.Unghostify(token)
+			r.Nil(err)
+			r.Equal(fmt.Sprintf("token %d", i+1), gs.Str)
+		}
+
+		var note string
+		r.Nil(db.QueryRowContext(ctx, "SELECT note FROM accounts WHERE id = 4").Scan(&note))
+		r.Equal("plain 4", note)
+
+		progress, err = ghoststring.RewrapSQL(ctx, db, "accounts", "id", []string{"token", "note"})
+		r.Nil(err)
+		r.Equal(int64(25), progress.Rows)
+		r.Equal(int64(0), progress.Rewrapped)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		r := require.New(t)
+
+		db := newDB(r)
+		defer db.Close()
+
+		checkpoint := ghoststring.NewFileSQLCheckpoint(filepath.Join(t.TempDir(), "checkpoint"))
+		r.Nil(checkpoint.Save(ctx, "10"))
+
+		progress, err := ghoststring.RewrapSQL(
+			ctx, db, "accounts", "id", []string{"token"},
+			ghoststring.WithSQLBatchSize(10),
+			ghoststring.WithSQLCheckpoint(checkpoint),
+		)
+		r.Nil(err)
+		r.Equal(int64(15), progress.Rows)
+		r.Equal(int64(15), progress.Rewrapped)
+
+		for i, token := range readTokens(r, db) {
+			_, err := oldGh.Unghostify(token)
+			r.Equal(i < 10, err == nil)
+		}
+	})
+
+	t.Run("blob primary key", func(t *testing.T) {
+		r := require.New(t)
+
+		db, err := sql.Open("sqlite", ":memory:")
+		r.Nil(err)
+		defer db.Close()
+
+		db.SetMaxOpenConns(1)
+
+		_, err = db.ExecContext(ctx, "CREATE TABLE devices (id BLOB PRIMARY KEY, token TEXT)")
+		r.Nil(err)
+
+		for i := 1; i <= 5; i++ {
+			token, err := oldGh.Ghostify(&ghoststring.GhostString{Namespace: "test.sqlrewrap", Str: fmt.Sprintf("device %d", i)})
+			r.Nil(err)
+
+			_, err = db.ExecContext(ctx, "INSERT INTO devices (id, token) VALUES (?, ?)", []byte{0xff, byte(i)}, token)
+			r.Nil(err)
+		}
+
+		progress, err := ghoststring.RewrapSQL(ctx, db, "devices", "id", []string{"token"}, ghoststring.WithSQLBatchSize(2))
+		r.Nil(err)
+		r.Equal(int64(5), progress.Rows)
+		r.Equal(int64(5), progress.Rewrapped)
+		r.Equal(string([]byte{0xff, 5}), progress.Checkpoint)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		r := require.New(t)
+
+		db := newDB(r)
+		defer db.Close()
+
+		stranger, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.sqlrewrap.stranger", "unknown")
+		r.Nil(err)
+
+		token, err := stranger.Ghostify(&ghoststring.GhostString{Namespace: "test.sqlrewrap.stranger", Str: "who"})
+		r.Nil(err)
+
+		_, err = db.ExecContext(ctx, "UPDATE accounts SET token = ? WHERE id = 3", token)
+		r.Nil(err)
+
+		progress, err := ghoststring.RewrapSQL(ctx, db, "accounts", "id", []string{"token"})
+		r.ErrorIs(err, ghoststring.Err)
+		r.Equal(int64(0), progress.Rows)
+		r.Equal(int64(2), progress.Rewrapped)
+
+		progress, err = ghoststring.RewrapSQL(ctx, db, "accounts", "id", []string{"token"}, ghoststring.WithSQLSkipErrors())
+		r.Nil(err)
+		r.Equal(int64(22), progress.Rewrapped)
+		r.Equal(int64(1), progress.Failed)
+
+		_, err = ghoststring.RewrapSQL(ctx, db, "accounts; DROP TABLE accounts", "id", []string{"token"})
+		r.ErrorIs(err, ghoststring.Err)
+
+		_, err = ghoststring.RewrapSQL(ctx, db, "accounts", "id", nil)
+		r.ErrorIs(err, ghoststring.Err)
+	})
+}
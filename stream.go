@@ -0,0 +1,348 @@
+package ghoststring
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// StreamPrefix begins the segmented stream form of ghostified
+	// data written by a GhostWriter, which is followed by a header
+	// of the form:
+	//
+	//	  {uint16 namespace length}{namespace}{salt}
+	//
+	// and then by a sequence of AES-256-GCM encrypted segments of
+	// StreamSegmentSize bytes of plain text each, the last of which
+	// may be shorter and is flagged in its nonce. Each segment is
+	// encrypted with a key derived from the salt and the namespace's
+	// key, using the header as additional authenticated data.
+	StreamPrefix = "👻\x01"
+
+	// StreamSegmentSize is the plain text size of each segment in the
+	// stream form.
+	StreamSegmentSize = 64 * 1024
+
+	streamSaltLen    = 32
+	streamTagLen     = 16
+	streamInfoPrefix = argon2SaltPrefix + "stream:"
+	streamLastFlag   = 1
+)
+
+// streamKeyer is implemented by Ghostifyers that are able to
+// provide their keys for use in the stream form.
+type streamKeyer interface {
+	streamKeys(ctx context.Context) ([]byte, [][]byte, error)
+}
+
+var (
+	_ streamKeyer = &aes256GcmSingleKeyGhostifyer{}
+	_ streamKeyer = &aes256GcmMultiKeyGhostifyer{}
+)
+
+// NewGhostWriter creates a writer that ghostifies everything
+// written to it in the stream form using the latest key of the
+// Ghostifyer registered for the namespace. The returned writer
+// must be closed to write the final segment, without which the
+// stream is considered truncated. Closing the returned writer does
+// not close w.
+func NewGhostWriter(w io.Writer, namespace string) (io.WriteCloser, error) {
+	latest, _, err := getStreamKeys(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, streamSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	header := toStreamHeader(namespace, salt)
+
+	aead, err := newStreamAEAD(latest, salt, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &ghostWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, StreamSegmentSize+1),
+	}, nil
+}
+
+// NewGhostReader creates a reader that unghostifies data in the
+// stream form using any key of the Ghostifyer registered for the
+// namespace found in the stream header. Reading returns an error
+// if any segment fails authentication or the stream is truncated.
+func NewGhostReader(r io.Reader) (io.Reader, error) {
+	gr, _, err := newGhostReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return gr, nil
+}
+
+// newGhostReader is NewGhostReader that also returns the namespace
+// found in the stream header.
+func newGhostReader(r io.Reader) (*ghostReader, string, error) {
+	br := bufio.NewReaderSize(r, StreamSegmentSize+streamTagLen+1)
+
+	namespace, salt, header, err := readStreamHeader(br)
+	if err != nil {
+		return nil, "", err
+	}
+
+	_, allKeys, err := getStreamKeys(namespace)
+	if err != nil {
+		return nil, "", err
+	}
+
+	gr := &ghostReader{r: br, header: header}
+
+	segment, last, err := gr.readSegment()
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, kb := range allKeys {
+		aead, err := newStreamAEAD(kb, salt, namespace)
+		if err != nil {
+			return nil, "", err
+		}
+
+		plainText, err := aead.Open(nil, streamNonce(0, last), segment, header)
+		if err != nil {
+			continue
+		}
+
+		gr.aead = aead
+		gr.plain = plainText
+		gr.counter = 1
+		gr.done = last
+
+		return gr, namespace, nil
+	}
+
+	return nil, "", errors.Wrap(Err, "no valid decryption key")
+}
+
+type ghostWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	counter uint32
+	closed  bool
+}
+
+func (gw *ghostWriter) Write(p []byte) (int, error) {
+	if gw.closed {
+		return 0, errors.Wrap(Err, "write to closed stream")
+	}
+
+	n := 0
+
+	for len(p) > 0 {
+		room := StreamSegmentSize + 1 - len(gw.buf)
+		if room > len(p) {
+			room = len(p)
+		}
+
+		gw.buf = append(gw.buf, p[:room]...)
+		p = p[room:]
+		n += room
+
+		// A full segment is only written once at least one more byte
+		// is known to follow, so that the final segment is never
+		// mistaken for an intermediate one.
+		if len(gw.buf) > StreamSegmentSize {
+			if err := gw.writeSegment(gw.buf[:StreamSegmentSize], false); err != nil {
+				return n, err
+			}
+
+			gw.buf = append(gw.buf[:0], gw.buf[StreamSegmentSize:]...)
+		}
+	}
+
+	return n, nil
+}
+
+func (gw *ghostWriter) Close() error {
+	if gw.closed {
+		return nil
+	}
+
+	gw.closed = true
+
+	return gw.writeSegment(gw.buf, true)
+}
+
+func (gw *ghostWriter) writeSegment(plainText []byte, last bool) error {
+	if gw.counter == math.MaxUint32 {
+		return errors.Wrap(Err, "stream too long")
+	}
+
+	_, err := gw.w.Write(gw.aead.Seal(nil, streamNonce(gw.counter, last), plainText, gw.header))
+	gw.counter++
+
+	return err
+}
+
+type ghostReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	plain   []byte
+	counter uint32
+	done    bool
+}
+
+func (gr *ghostReader) Read(p []byte) (int, error) {
+	for len(gr.plain) == 0 {
+		if gr.done {
+			return 0, io.EOF
+		}
+
+		segment, last, err := gr.readSegment()
+		if err != nil {
+			return 0, err
+		}
+
+		plainText, err := gr.aead.Open(nil, streamNonce(gr.counter, last), segment, gr.header)
+		if err != nil {
+			return 0, errors.Wrap(Err, "invalid stream segment")
+		}
+
+		gr.plain = plainText
+		gr.counter++
+		gr.done = last
+	}
+
+	n := copy(p, gr.plain)
+	gr.plain = gr.plain[n:]
+
+	return n, nil
+}
+
+// readSegment reads the next encrypted segment, which is the last
+// segment if it is shorter than a full segment or is followed by
+// the end of the stream.
+func (gr *ghostReader) readSegment() ([]byte, bool, error) {
+	segment := make([]byte, StreamSegmentSize+streamTagLen)
+
+	n, err := io.ReadFull(gr.r, segment)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		if n < streamTagLen {
+			return nil, false, errors.Wrap(Err, "truncated stream")
+		}
+
+		return segment[:n], true, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if _, err := gr.r.Peek(1); err == io.EOF {
+		return segment, true, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return segment, false, nil
+}
+
+func getStreamKeys(namespace string) ([]byte, [][]byte, error) {
+	ghostifyer, ok := getGhostifyer(namespace)
+	if !ok {
+		return nil, nil, errors.Wrapf(Err, "no ghostifyer set for namespace %[1]q", namespace)
+	}
+
+	sk, ok := ghostifyer.(streamKeyer)
+	if !ok {
+		return nil, nil, errors.Wrapf(Err, "ghostifyer for namespace %[1]q does not support streams", namespace)
+	}
+
+	return sk.streamKeys(context.TODO())
+}
+
+func newStreamAEAD(key, salt []byte, namespace string) (cipher.AEAD, error) {
+	streamKey := make([]byte, aesKeyLen)
+
+	if _, err := io.ReadFull(
+		hkdf.New(sha256.New, key, salt, []byte(streamInfoPrefix+namespace)),
+		streamKey,
+	); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(streamKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// streamNonce is the segment counter followed by a flag marking the
+// last segment, with the remaining leading bytes left as zero
+// because each stream uses a distinct derived key.
+func streamNonce(counter uint32, last bool) []byte {
+	nonce := make([]byte, Nonce)
+
+	binary.BigEndian.PutUint32(nonce[Nonce-5:Nonce-1], counter)
+
+	if last {
+		nonce[Nonce-1] = streamLastFlag
+	}
+
+	return nonce
+}
+
+func toStreamHeader(namespace string, salt []byte) []byte {
+	header := []byte(StreamPrefix)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(namespace)))
+	header = append(header, []byte(namespace)...)
+
+	return append(header, salt...)
+}
+
+func readStreamHeader(r io.Reader) (string, []byte, []byte, error) {
+	prefixLen := make([]byte, len(StreamPrefix)+2)
+	if _, err := io.ReadFull(r, prefixLen); err != nil {
+		return "", nil, nil, errors.Wrap(Err, "truncated stream header")
+	}
+
+	if string(prefixLen[:len(StreamPrefix)]) != StreamPrefix {
+		return "", nil, nil, errors.Wrap(Err, "invalid stream prefix")
+	}
+
+	nsSalt := make([]byte, int(binary.BigEndian.Uint16(prefixLen[len(StreamPrefix):]))+streamSaltLen)
+	if _, err := io.ReadFull(r, nsSalt); err != nil {
+		return "", nil, nil, errors.Wrap(Err, "truncated stream header")
+	}
+
+	namespace := string(nsSalt[:len(nsSalt)-streamSaltLen])
+	if err := validateNamespace(namespace); err != nil {
+		return "", nil, nil, err
+	}
+
+	return namespace, nsSalt[len(namespace):], append(prefixLen, nsSalt...), nil
+}
@@ -0,0 +1,134 @@
+package ghoststring_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostStream(t *testing.T) {
+	ks, err := ghoststring.NewKeyStore(
+		"test.stream",
+		[]*ghoststring.TimestampedKey{
+			{Timestamp: 1661351759000, Key: "river deep"},
+			{Timestamp: 1661351742000, Key: "mountain high"},
+		},
+	)
+	require.Nil(t, err)
+
+	require.Nil(t, ghoststring.SetGhostifyer(ghoststring.NewAES256GCMMultiKeyGhostifyer("test.stream", ks)))
+
+	for _, size := range []int{
+		0,
+		1,
+		ghoststring.StreamSegmentSize - 1,
+		ghoststring.StreamSegmentSize,
+		ghoststring.StreamSegmentSize + 1,
+		3*ghoststring.StreamSegmentSize + 17,
+	} {
+		t.Run(fmt.Sprintf("size=%[1]v", size), func(t *testing.T) {
+			r := require.New(t)
+
+			plainText := make([]byte, size)
+			_, err := rand.Read(plainText)
+			r.Nil(err)
+
+			enc := &bytes.Buffer{}
+
+			w, err := ghoststring.NewGhostWriter(enc, "test.stream")
+			r.Nil(err)
+
+			// odd-sized writes to exercise segment boundaries
+			for rest := plainText; len(rest) > 0; {
+				n := 1000
+				if n > len(rest) {
+					n = len(rest)
+				}
+
+				written, err := w.Write(rest[:n])
+				r.Nil(err)
+				r.Equal(n, written)
+
+				rest = rest[n:]
+			}
+
+			r.Nil(w.Close())
+			r.True(bytes.HasPrefix(enc.Bytes(), []byte(ghoststring.StreamPrefix)))
+
+			encBytes := enc.Bytes()
+
+			gr, err := ghoststring.NewGhostReader(bytes.NewReader(encBytes))
+			r.Nil(err)
+
+			decrypted, err := io.ReadAll(gr)
+			r.Nil(err)
+			r.Equal(plainText, append([]byte{}, decrypted...))
+
+			if size <= ghoststring.StreamSegmentSize {
+				return
+			}
+
+			truncated := encBytes[:len(encBytes)-(size%ghoststring.StreamSegmentSize)-16]
+
+			gr, err = ghoststring.NewGhostReader(bytes.NewReader(truncated))
+			if err == nil {
+				_, err = io.ReadAll(gr)
+			}
+			r.ErrorIs(err, ghoststring.Err)
+
+			tampered := append([]byte{}, encBytes...)
+			tampered[len(tampered)-1] ^= 0xff
+
+			gr, err = ghoststring.NewGhostReader(bytes.NewReader(tampered))
+			r.Nil(err)
+
+			_, err = io.ReadAll(gr)
+			r.ErrorIs(err, ghoststring.Err)
+		})
+	}
+}
+
+func TestGhostStreamKeyRotation(t *testing.T) {
+	r := require.New(t)
+
+	old, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.stream.rotation", "mountain high")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(old))
+
+	enc := &bytes.Buffer{}
+
+	w, err := ghoststring.NewGhostWriter(enc, "test.stream.rotation")
+	r.Nil(err)
+
+	_, err = w.Write([]byte("ain't no mountain high enough"))
+	r.Nil(err)
+	r.Nil(w.Close())
+
+	ks, err := ghoststring.NewKeyStore(
+		"test.stream.rotation",
+		[]*ghoststring.TimestampedKey{
+			{Timestamp: 1661351759000, Key: "river deep"},
+			{Timestamp: 1661351742000, Key: "mountain high"},
+		},
+	)
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(ghoststring.NewAES256GCMMultiKeyGhostifyer("test.stream.rotation", ks)))
+
+	gr, err := ghoststring.NewGhostReader(bytes.NewReader(enc.Bytes()))
+	r.Nil(err)
+
+	decrypted, err := io.ReadAll(gr)
+	r.Nil(err)
+	r.Equal("ain't no mountain high enough", string(decrypted))
+
+	_, err = ghoststring.NewGhostWriter(&bytes.Buffer{}, "test.stream.unregistered")
+	r.ErrorIs(err, ghoststring.Err)
+
+	_, err = ghoststring.NewGhostReader(bytes.NewReader([]byte("not a stream at all")))
+	r.ErrorIs(err, ghoststring.Err)
+}
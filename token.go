@@ -0,0 +1,170 @@
+package ghoststring
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTokenMaxAge is the default max age of tokens sealed via
+	// SealToken. See WithTokenMaxAge.
+	DefaultTokenMaxAge = 10 * time.Minute
+
+	// tokenPurpose is the HeaderPurpose of every sealed token, which
+	// prevents other values in the namespace, such as sessions
+	// stored by a CookieStore, from being opened as tokens.
+	tokenPurpose = "token"
+)
+
+// TokenOption configures optional behavior of SealToken and
+// OpenToken.
+type TokenOption func(*tokenOptions)
+
+type tokenOptions struct {
+	maxAge   time.Duration
+	audience string
+	oneTime  bool
+}
+
+// WithTokenMaxAge sets the max age of sealed tokens. The default is
+// DefaultTokenMaxAge.
+func WithTokenMaxAge(maxAge time.Duration) TokenOption {
+	return func(o *tokenOptions) {
+		if maxAge > 0 {
+			o.maxAge = maxAge
+		}
+	}
+}
+
+// WithTokenAudience sets the audience of sealed tokens, such as the
+// redirect URL or client for which they are intended, which must
+// match exactly when opened.
+func WithTokenAudience(audience string) TokenOption {
+	return func(o *tokenOptions) {
+		o.audience = audience
+	}
+}
+
+// WithTokenOneTime enables a TokenID for sealed tokens so that they
+// are rejected with ErrReplayed after being opened once by a
+// Ghostifyer configured via WithReplayCache, such as for OAuth
+// state.
+func WithTokenOneTime() TokenOption {
+	return func(o *tokenOptions) {
+		o.oneTime = true
+	}
+}
+
+func newTokenOptions(opts []TokenOption) *tokenOptions {
+	o := &tokenOptions{maxAge: DefaultTokenMaxAge}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// SealToken encodes the value as JSON and ghostifies it with the
+// Ghostifyer registered for the namespace into a time-limited token
+// that may be used in URLs, such as in a redirect or as OAuth state,
+// without escaping.
+func SealToken[T any](namespace string, value T, opts ...TokenOption) (string, error) {
+	o := newTokenOptions(opts)
+
+	ghostifyer, ok := getGhostifyer(namespace)
+	if !ok {
+		return "", errors.Wrapf(Err, "no ghostifyer set for namespace %[1]q", namespace)
+	}
+
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	gs := &GhostString{Namespace: namespace, Str: string(valueBytes), MaxAge: o.maxAge}
+
+	header := Header{HeaderPurpose: tokenPurpose}
+	if o.audience != "" {
+		header[HeaderAudience] = o.audience
+	}
+
+	if o.oneTime {
+		tokenID, err := NewTokenID()
+		if err != nil {
+			return "", err
+		}
+
+		gs.TokenID = tokenID
+	}
+
+	s, err := ghostifyWithHeader(ghostifyer, gs, header)
+	if err != nil {
+		return "", err
+	}
+
+	return toURLSafe(s)
+}
+
+// OpenToken unghostifies a token sealed via SealToken in the
+// namespace and decodes its value. An error matching ErrExpired is
+// returned if the token has expired and one matching ErrAudience if
+// the token was not sealed for the audience given via
+// WithTokenAudience. Such tokens are rejected before a one-time
+// token is recorded as used, so that it may still be opened by the
+// intended party. The max age and one-time options are ignored.
+func OpenToken[T any](namespace, token string, opts ...TokenOption) (*T, error) {
+	o := newTokenOptions(opts)
+
+	// The namespace and audience are checked before unghostifying so
+	// that a one-time token is not recorded as used when opened by
+	// the wrong party, and again once they are authenticated.
+	unParts, err := toUnghostifyParts(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkToken(unParts.namespace, unParts.header, namespace, o); err != nil {
+		return nil, err
+	}
+
+	un, err := metaUnghostify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	gs := un.GhostString
+
+	if err := checkToken(gs.Namespace, un.Header, namespace, o); err != nil {
+		return nil, err
+	}
+
+	if gs.MaxAge <= 0 {
+		return nil, errors.Wrap(Err, "token without max age")
+	}
+
+	value := new(T)
+	if err := json.Unmarshal([]byte(gs.Str), value); err != nil {
+		return nil, errors.Wrap(Err, "invalid token value")
+	}
+
+	return value, nil
+}
+
+func checkToken(tokenNamespace string, header Header, namespace string, o *tokenOptions) error {
+	if tokenNamespace != namespace {
+		return errors.Wrapf(Err, "token namespace %[1]q is not %[2]q", tokenNamespace, namespace)
+	}
+
+	if purpose := header[HeaderPurpose]; purpose != tokenPurpose {
+		return errors.Wrapf(Err, "purpose %[1]q is not %[2]q", purpose, tokenPurpose)
+	}
+
+	if audience := header[HeaderAudience]; audience != o.audience {
+		return errors.Wrapf(ErrAudience, "token audience %[1]q is not %[2]q", audience, o.audience)
+	}
+
+	return nil
+}
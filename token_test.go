@@ -0,0 +1,152 @@
+package ghoststring_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+type oauthState struct {
+	ReturnTo string `json:"return_to"`
+	Nonce    string `json:"nonce"`
+}
+
+func TestToken(t *testing.T) {
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	gh, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+		"test.tokens",
+		"state of the art",
+		ghoststring.WithClock(func() time.Time { return now }),
+	)
+	require.Nil(t, err)
+	require.Nil(t, ghoststring.SetGhostifyer(gh))
+
+	state := oauthState{ReturnTo: "/fjord?x=1&y=2", Nonce: "giddy"}
+
+	t.Run("round trip", func(t *testing.T) {
+		r := require.New(t)
+
+		token, err := ghoststring.SealToken("test.tokens", state, ghoststring.WithTokenAudience("https://idp.example.org"))
+		r.Nil(err)
+		r.Equal(token, url.PathEscape(token))
+		r.NotContains(token, "fjord")
+
+		redirect := &url.URL{Path: "/authorize", RawQuery: url.Values{"state": {token}}.Encode()}
+
+		parsed, err := url.Parse(redirect.String())
+		r.Nil(err)
+
+		opened, err := ghoststring.OpenToken[oauthState](
+			"test.tokens",
+			parsed.Query().Get("state"),
+			ghoststring.WithTokenAudience("https://idp.example.org"),
+		)
+		r.Nil(err)
+		r.Equal(&state, opened)
+
+		_, err = ghoststring.OpenToken[oauthState]("test.tokens", token, ghoststring.WithTokenAudience("https://evil.example.org"))
+		r.ErrorIs(err, ghoststring.ErrAudience)
+
+		_, err = ghoststring.OpenToken[oauthState]("test.tokens", token)
+		r.ErrorIs(err, ghoststring.ErrAudience)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		r := require.New(t)
+
+		token, err := ghoststring.SealToken("test.tokens", "short lived", ghoststring.WithTokenMaxAge(time.Minute))
+		r.Nil(err)
+
+		now = now.Add(2 * time.Minute)
+		defer func() { now = now.Add(-2 * time.Minute) }()
+
+		_, err = ghoststring.OpenToken[string]("test.tokens", token)
+		r.ErrorIs(err, ghoststring.ErrExpired)
+	})
+
+	t.Run("one time", func(t *testing.T) {
+		r := require.New(t)
+
+		once, err := ghoststring.NewAES256GCMSingleKeyGhostifyer(
+			"test.tokens.once",
+			"state of the art",
+			ghoststring.WithReplayCache(ghoststring.NewInMemoryReplayCache(time.Hour)),
+		)
+		r.Nil(err)
+		r.Nil(ghoststring.SetGhostifyer(once))
+
+		token, err := ghoststring.SealToken(
+			"test.tokens.once",
+			state,
+			ghoststring.WithTokenOneTime(),
+			ghoststring.WithTokenAudience("https://idp.example.org"),
+		)
+		r.Nil(err)
+
+		_, err = ghoststring.OpenToken[oauthState]("test.tokens.once", token, ghoststring.WithTokenAudience("https://evil.example.org"))
+		r.ErrorIs(err, ghoststring.ErrAudience)
+
+		_, err = ghoststring.OpenToken[oauthState]("test.tokens", token, ghoststring.WithTokenAudience("https://idp.example.org"))
+		r.ErrorIs(err, ghoststring.Err)
+
+		_, err = ghoststring.OpenToken[oauthState]("test.tokens.once", token, ghoststring.WithTokenAudience("https://idp.example.org"))
+		r.Nil(err)
+
+		_, err = ghoststring.OpenToken[oauthState]("test.tokens.once", token, ghoststring.WithTokenAudience("https://idp.example.org"))
+		r.ErrorIs(err, ghoststring.ErrReplayed)
+	})
+
+	t.Run("cross use", func(t *testing.T) {
+		r := require.New(t)
+
+		cs, err := ghoststring.NewCookieStore[oauthState]("test.tokens", "state")
+		r.Nil(err)
+
+		rec := httptest.NewRecorder()
+		r.Nil(cs.Save(rec, httptest.NewRequest(http.MethodGet, "/", nil), &state))
+
+		cookies := rec.Result().Cookies()
+		r.Len(cookies, 1)
+
+		_, err = ghoststring.OpenToken[oauthState]("test.tokens", cookies[0].Value)
+		r.ErrorIs(err, ghoststring.Err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+
+		loaded, err := cs.Load(req)
+		r.Nil(err)
+		r.Equal(&state, loaded)
+
+		token, err := ghoststring.SealToken("test.tokens", state, ghoststring.WithTokenMaxAge(ghoststring.DefaultCookieMaxAge))
+		r.Nil(err)
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "state", Value: token})
+
+		_, err = cs.Load(req)
+		r.ErrorIs(err, ghoststring.Err)
+	})
+
+	t.Run("not a token", func(t *testing.T) {
+		r := require.New(t)
+
+		s, err := gh.Ghostify(&ghoststring.GhostString{Namespace: "test.tokens", Str: `"forever"`})
+		r.Nil(err)
+
+		_, err = ghoststring.OpenToken[string]("test.tokens", s)
+		r.ErrorIs(err, ghoststring.Err)
+
+		_, err = ghoststring.OpenToken[string]("test.tokens", "garbage")
+		r.ErrorIs(err, ghoststring.Err)
+
+		_, err = ghoststring.SealToken("test.tokens.unregistered", state)
+		r.ErrorIs(err, ghoststring.Err)
+	})
+}
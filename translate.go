@@ -0,0 +1,124 @@
+package ghoststring
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TranslationRule allows values to be translated from one namespace
+// to another.
+type TranslationRule struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// TranslationEvent is the audit record of an attempt to translate a
+// value, which is reported whether or not it succeeded.
+type TranslationEvent struct {
+	Time    time.Time
+	From    string
+	To      string
+	TokenID string
+
+	// Err is the reason the translation failed, if it did.
+	Err error
+}
+
+// Translator re-ghostifies values from one namespace into another
+// as allowed by its rules, such as when data moves between
+// services, without returning the plaintext to the caller.
+type Translator struct {
+	// Rules lists the namespace pairs that may be translated. A
+	// translation that does not match a rule fails with an error
+	// matching ErrNotAllowed.
+	Rules []TranslationRule
+
+	// Audit, if set, is called with every attempted translation.
+	Audit func(*TranslationEvent)
+
+	// Lookup returns the Ghostifyer for a namespace, which defaults
+	// to those registered via SetGhostifyer.
+	Lookup func(namespace string) (Ghostifyer, bool)
+}
+
+// Translate unghostifies the value and ghostifies it again in the
+// namespace, retaining its issue time, max age, token ID, and
+// header. Values that have expired or been replayed are not
+// translated.
+func (t *Translator) Translate(s, namespace string) (string, error) {
+	event := &TranslationEvent{Time: time.Now(), To: namespace}
+
+	translated, err := t.translate(s, event)
+
+	if t.Audit != nil {
+		event.Err = err
+		t.Audit(event)
+	}
+
+	return translated, err
+}
+
+func (t *Translator) translate(s string, event *TranslationEvent) (string, error) {
+	from, err := PeekNamespace(s)
+	if err != nil {
+		return "", err
+	}
+
+	event.From = from
+
+	if !t.allows(from, event.To) {
+		return "", errors.Wrapf(ErrNotAllowed, "translation from %[1]q to %[2]q", from, event.To)
+	}
+
+	fromGhostifyer, err := t.lookup(from)
+	if err != nil {
+		return "", err
+	}
+
+	toGhostifyer, err := t.lookup(event.To)
+	if err != nil {
+		return "", err
+	}
+
+	un, err := unghostifyDetailed(fromGhostifyer, s)
+	if err != nil {
+		return "", err
+	}
+
+	gs := un.GhostString
+
+	event.TokenID = gs.TokenID
+
+	if gs.Namespace != from {
+		return "", errors.Wrapf(Err, "value namespace %[1]q is not %[2]q", gs.Namespace, from)
+	}
+
+	gs.Namespace = event.To
+
+	return ghostifyWithHeader(toGhostifyer, gs, un.UserHeader())
+}
+
+func (t *Translator) allows(from, to string) bool {
+	for _, rule := range t.Rules {
+		if rule.From == from && rule.To == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Translator) lookup(namespace string) (Ghostifyer, error) {
+	lookup := t.Lookup
+	if lookup == nil {
+		lookup = getGhostifyer
+	}
+
+	ghostifyer, ok := lookup(namespace)
+	if !ok {
+		return nil, errors.Wrapf(Err, "no ghostifyer set for namespace %[1]q", namespace)
+	}
+
+	return ghostifyer, nil
+}
@@ -0,0 +1,146 @@
+package ghoststring_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rstudio/ghoststring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslator(t *testing.T) {
+	r := require.New(t)
+
+	ghA, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.translate.a", "departure lounge")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(ghA))
+
+	ghB, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.translate.b", "arrivals hall")
+	r.Nil(err)
+	r.Nil(ghoststring.SetGhostifyer(ghB))
+
+	events := []*ghoststring.TranslationEvent{}
+
+	tr := &ghoststring.Translator{
+		Rules: []ghoststring.TranslationRule{{From: "test.translate.a", To: "test.translate.b"}},
+		Audit: func(event *ghoststring.TranslationEvent) {
+			events = append(events, event)
+		},
+	}
+
+	issuedAt := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+
+	s, err := ghA.(ghoststring.HeaderGhostifyer).GhostifyWithHeader(
+		&ghoststring.GhostString{
+			Namespace: "test.translate.a",
+			Str:       "carry on",
+			MaxAge:    time.Hour,
+			IssuedAt:  issuedAt,
+			TokenID:   "boarding-pass",
+		},
+		ghoststring.Header{ghoststring.HeaderPurpose: "luggage"},
+	)
+	r.Nil(err)
+
+	t.Run("allowed", func(t *testing.T) {
+		r := require.New(t)
+
+		translated, err := tr.Translate(s, "test.translate.b")
+		r.Nil(err)
+		r.NotContains(translated, "carry on")
+
+		ns, err := ghoststring.PeekNamespace(translated)
+		r.Nil(err)
+		r.Equal("test.translate.b", ns)
+
+		_, err = ghA.Unghostify(translated)
+		r.Error(err)
+
+		un, err := ghB.(ghoststring.DetailedUnghostifyer).UnghostifyDetailed(translated)
+		r.Nil(err)
+
+		gs := un.GhostString
+		r.Equal("test.translate.b", gs.Namespace)
+		r.Equal("carry on", gs.Str)
+		r.Equal(time.Hour, gs.MaxAge)
+		r.True(issuedAt.Equal(gs.IssuedAt))
+		r.Equal("boarding-pass", gs.TokenID)
+		r.Equal("luggage", un.Header[ghoststring.HeaderPurpose])
+
+		event := events[len(events)-1]
+		r.Equal("test.translate.a", event.From)
+		r.Equal("test.translate.b", event.To)
+		r.Equal("boarding-pass", event.TokenID)
+		r.Nil(event.Err)
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		r := require.New(t)
+
+		translated, err := ghB.Ghostify(&ghoststring.GhostString{Namespace: "test.translate.b", Str: "return trip"})
+		r.Nil(err)
+
+		_, err = tr.Translate(translated, "test.translate.a")
+		r.ErrorIs(err, ghoststring.ErrNotAllowed)
+
+		event := events[len(events)-1]
+		r.Equal("test.translate.b", event.From)
+		r.Equal("test.translate.a", event.To)
+		r.ErrorIs(event.Err, ghoststring.ErrNotAllowed)
+
+		_, err = tr.Translate("not ghostified", "test.translate.b")
+		r.Error(err)
+		r.NotNil(events[len(events)-1].Err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		r := require.New(t)
+
+		expired, err := ghA.Ghostify(
+			&ghoststring.GhostString{
+				Namespace: "test.translate.a",
+				Str:       "missed flight",
+				MaxAge:    time.Minute,
+				IssuedAt:  time.Now().Add(-time.Hour),
+			},
+		)
+		r.Nil(err)
+
+		_, err = tr.Translate(expired, "test.translate.b")
+		r.ErrorIs(err, ghoststring.ErrExpired)
+	})
+
+	t.Run("lookup", func(t *testing.T) {
+		r := require.New(t)
+
+		ghC, err := ghoststring.NewAES256GCMSingleKeyGhostifyer("test.translate.c", "transit")
+		r.Nil(err)
+
+		lookupTr := &ghoststring.Translator{
+			Rules: []ghoststring.TranslationRule{
+				{From: "test.translate.a", To: "test.translate.c"},
+				{From: "test.translate.a", To: "test.translate.d"},
+			},
+			Lookup: func(namespace string) (ghoststring.Ghostifyer, bool) {
+				switch namespace {
+				case "test.translate.a":
+					return ghA, true
+				case "test.translate.c":
+					return ghC, true
+				}
+
+				return nil, false
+			},
+		}
+
+		translated, err := lookupTr.Translate(s, "test.translate.c")
+		r.Nil(err)
+
+		gs, err := ghC.Unghostify(translated)
+		r.Nil(err)
+		r.Equal("carry on", gs.Str)
+
+		_, err = lookupTr.Translate(s, "test.translate.d")
+		r.ErrorIs(err, ghoststring.Err)
+	})
+}